@@ -11,7 +11,7 @@ type Peer struct {
 	Id     [20]byte
 	IP     net.IP
 	Port   int
-	Source peerSource
+	Source PeerSource
 	// Peer is known to support encryption.
 	SupportsEncryption bool
 	peer_protocol.PexPeerFlags
@@ -20,7 +20,7 @@ type Peer struct {
 func (me *Peer) FromPex(na krpc.NodeAddr, fs peer_protocol.PexPeerFlags) {
 	me.IP = append([]byte(nil), na.IP...)
 	me.Port = na.Port
-	me.Source = peerSourcePEX
+	me.Source = PeerSourcePEX
 	// If they prefer encryption, they must support it.
 	if fs.Get(peer_protocol.PexPrefersEncryption) {
 		me.SupportsEncryption = true
@@ -25,7 +25,7 @@ func (ret Peers) AppendFromTracker(ps []tracker.Peer) Peers {
 		_p := Peer{
 			IP:     p.IP,
 			Port:   p.Port,
-			Source: peerSourceTracker,
+			Source: PeerSourceTracker,
 		}
 		copy(_p.Id[:], p.ID)
 		ret = append(ret, _p)
@@ -0,0 +1,90 @@
+package torrent
+
+import (
+	"time"
+)
+
+const (
+	// How often the adaptive readahead rates are resampled.
+	adaptiveReadaheadSampleInterval = time.Second
+	// Weight given to the newest sample in the smoothed rates.
+	adaptiveReadaheadSmoothing = 0.3
+	// How far ahead of the read position, in time at the observed read rate, we want data to be
+	// prioritized.
+	adaptiveReadaheadHorizon = 10 * time.Second
+	// Limits how much the horizon is stretched when the torrent downloads slower than it's read.
+	adaptiveReadaheadMaxStretch = 4
+)
+
+// Tracks the rate a reader consumes data, and the rate its torrent is downloading, to determine a
+// readahead that should keep a consumer at that rate from stalling, without prioritizing pieces
+// that won't be needed for a long time.
+type adaptiveReadahead struct {
+	min, max int64
+
+	sampleStarted      time.Time
+	sampleBytesRead    int64
+	sampleTorrentBytes int64
+
+	// Smoothed rates in bytes per second.
+	readRate     float64
+	downloadRate float64
+}
+
+func newAdaptiveReadahead(min, max int64, now time.Time, torrentBytes int64) *adaptiveReadahead {
+	if max < min {
+		max = min
+	}
+	return &adaptiveReadahead{
+		min:                min,
+		max:                max,
+		sampleStarted:      now,
+		sampleTorrentBytes: torrentBytes,
+	}
+}
+
+// Records bytes read, and the torrent's cumulative useful bytes downloaded. Returns true if the
+// rates were resampled.
+func (me *adaptiveReadahead) update(now time.Time, read, torrentBytes int64) bool {
+	me.sampleBytesRead += read
+	elapsed := now.Sub(me.sampleStarted)
+	if elapsed < adaptiveReadaheadSampleInterval {
+		return false
+	}
+	secs := elapsed.Seconds()
+	me.readRate = smoothRate(me.readRate, float64(me.sampleBytesRead)/secs)
+	me.downloadRate = smoothRate(me.downloadRate, float64(torrentBytes-me.sampleTorrentBytes)/secs)
+	me.sampleStarted = now
+	me.sampleBytesRead = 0
+	me.sampleTorrentBytes = torrentBytes
+	return true
+}
+
+func smoothRate(old, sample float64) float64 {
+	if old == 0 {
+		return sample
+	}
+	return adaptiveReadaheadSmoothing*sample + (1-adaptiveReadaheadSmoothing)*old
+}
+
+// The readahead to use given the observed rates. fallback is used until a read rate is known.
+func (me *adaptiveReadahead) readahead(pieceLength, fallback int64) int64 {
+	if me.readRate <= 0 {
+		return clamp(me.min, fallback, me.max)
+	}
+	horizon := adaptiveReadaheadHorizon.Seconds()
+	if me.downloadRate > 0 {
+		// Allow for the time it takes to obtain a whole piece, since nothing in it can be read
+		// until it's verified.
+		horizon += float64(pieceLength) / me.downloadRate
+		if me.downloadRate < me.readRate {
+			// We can't keep up. Reach further ahead so that bursts of download can build a buffer.
+			stretch := me.readRate / me.downloadRate
+			if stretch > adaptiveReadaheadMaxStretch {
+				stretch = adaptiveReadaheadMaxStretch
+			}
+			horizon *= stretch
+		}
+	}
+	return clamp(me.min, int64(me.readRate*horizon), me.max)
+}
@@ -0,0 +1,38 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestAdaptiveReadahead(t *testing.T) {
+	start := time.Unix(1000, 0)
+	ar := newAdaptiveReadahead(1<<20, 64<<20, start, 0)
+	// The fallback is used, clamped, until there's a read rate.
+	assert.EqualValues(t, 1<<20, ar.readahead(1<<18, 0))
+	assert.EqualValues(t, 64<<20, ar.readahead(1<<18, 1<<30))
+	assert.False(t, ar.update(start.Add(time.Second/2), 1<<20, 0))
+	// Reading 1 MiB/s and downloading 2 MiB/s: the horizon plus time to get a 256 KiB piece.
+	require.True(t, ar.update(start.Add(time.Second), 0, 2<<20))
+	assert.EqualValues(t, 10<<20+1<<17, ar.readahead(1<<18, 0))
+	// Downloading slower than reading stretches the horizon, up to the limit, and the maximum.
+	ar = newAdaptiveReadahead(0, 1<<40, start, 0)
+	ar.update(start.Add(time.Second), 1<<20, 1<<10)
+	assert.EqualValues(t, float64(1<<20)*(10+1<<8)*adaptiveReadaheadMaxStretch, ar.readahead(1<<18, 0))
+	ar.max = 32 << 20
+	assert.EqualValues(t, 32<<20, ar.readahead(1<<18, 0))
+}
+
+func TestReaderPieceLengthWithoutInfo(t *testing.T) {
+	cl := newTestingClient(t, testingConfig(t))
+	tor, _ := cl.AddTorrentInfoHash(metainfo.Hash{1})
+	r := reader{t: tor, mu: cl.locker()}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assert.EqualValues(t, 0, r.pieceLength())
+}
@@ -1,7 +1,6 @@
 package torrent
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -33,8 +32,6 @@ import (
 	"github.com/anacrolix/torrent/mse"
 	pp "github.com/anacrolix/torrent/peer_protocol"
 	"github.com/anacrolix/torrent/storage"
-	"github.com/davecgh/go-spew/spew"
-	humanize "github.com/dustin/go-humanize"
 	"github.com/google/btree"
 	"golang.org/x/time/rate"
 )
@@ -45,6 +42,8 @@ type Client struct {
 	// An aggregate of stats over all connections. First in struct to ensure 64-bit alignment of
 	// fields. See #262.
 	stats ConnStats
+	// Sliding-window transfer rates over all connections.
+	rates transferRates
 
 	_mu    sync.RWMutex
 	event  sync.Cond
@@ -56,9 +55,15 @@ type Client struct {
 	peerID         PeerID
 	defaultStorage *storage.Client
 	onClose        []func()
-	conns          []socket
-	dhtServers     []DhtServer
-	ipBlockList    iplist.Ranger
+	events         eventBus
+	// Nil if there are no ClientConfig.CompletionHooks.
+	completionHooks *completionHookRunner
+	metrics         Metrics
+	conns           []socket
+	dhtServers      []DhtServer
+	ipBlockList     iplist.Ranger
+	// For fetching metainfo from magnet sources.
+	httpClient *http.Client
 	// Our BitTorrent protocol extension bytes, sent in our BT handshakes.
 	extensionBytes pp.PeerExtensionBits
 
@@ -107,48 +112,11 @@ func (cl *Client) LocalPort() (port int) {
 	return
 }
 
-func writeDhtServerStatus(w io.Writer, s DhtServer) {
-	dhtStats := s.Stats()
-	fmt.Fprintf(w, " ID: %x\n", s.ID())
-	spew.Fdump(w, dhtStats)
-}
-
 // Writes out a human readable status of the client, such as for writing to a
 // HTTP status page.
-func (cl *Client) WriteStatus(_w io.Writer) {
-	cl.rLock()
-	defer cl.rUnlock()
-	w := bufio.NewWriter(_w)
-	defer w.Flush()
-	fmt.Fprintf(w, "Listen port: %d\n", cl.LocalPort())
-	fmt.Fprintf(w, "Peer ID: %+q\n", cl.PeerID())
-	fmt.Fprintf(w, "Announce key: %x\n", cl.announceKey())
-	fmt.Fprintf(w, "Banned IPs: %d\n", len(cl.badPeerIPsLocked()))
-	cl.eachDhtServer(func(s DhtServer) {
-		fmt.Fprintf(w, "%s DHT server at %s:\n", s.Addr().Network(), s.Addr().String())
-		writeDhtServerStatus(w, s)
-	})
-	spew.Fdump(w, cl.stats)
-	fmt.Fprintf(w, "# Torrents: %d\n", len(cl.torrentsAsSlice()))
-	fmt.Fprintln(w)
-	for _, t := range slices.Sort(cl.torrentsAsSlice(), func(l, r *Torrent) bool {
-		return l.InfoHash().AsString() < r.InfoHash().AsString()
-	}).([]*Torrent) {
-		if t.name() == "" {
-			fmt.Fprint(w, "<unknown name>")
-		} else {
-			fmt.Fprint(w, t.name())
-		}
-		fmt.Fprint(w, "\n")
-		if t.info != nil {
-			fmt.Fprintf(w, "%f%% of %d bytes (%s)", 100*(1-float64(t.bytesMissingLocked())/float64(t.info.TotalLength())), *t.length, humanize.Bytes(uint64(*t.length)))
-		} else {
-			w.WriteString("<missing metainfo>")
-		}
-		fmt.Fprint(w, "\n")
-		t.writeStatus(w)
-		fmt.Fprintln(w)
-	}
+func (cl *Client) WriteStatus(w io.Writer) {
+	s := cl.Status()
+	s.Write(w)
 }
 
 func (cl *Client) initLogger() {
@@ -195,6 +163,9 @@ func NewClient(cfg *ClientConfig) (cl *Client, err error) {
 	storageImpl := cfg.DefaultStorage
 
 	cl.defaultStorage = storage.NewClient(storageImpl)
+	if len(cfg.CompletionHooks) != 0 {
+		cl.completionHooks = newCompletionHookRunner(cfg, cl.logger.Printf)
+	}
 	if cfg.IPBlocklist != nil {
 		cl.ipBlockList = cfg.IPBlocklist
 	}
@@ -214,6 +185,14 @@ func NewClient(cfg *ClientConfig) (cl *Client, err error) {
 			cl.config.HTTPProxy = http.ProxyURL(fixedURL)
 		}
 	}
+	cl.httpClient = &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			Proxy:               cl.config.HTTPProxy,
+			TLSHandshakeTimeout: 15 * time.Second,
+		},
+	}
+	cl.onClose = append(cl.onClose, cl.httpClient.CloseIdleConnections)
 
 	cl.conns, err = listenAll(cl.listenNetworks(), cl.config.ListenHost, cl.config.ListenPort, cl.config.ProxyURL, cl.firewallCallback)
 	if err != nil {
@@ -249,9 +228,9 @@ func (cl *Client) firewallCallback(net.Addr) bool {
 	block := !cl.wantConns()
 	cl.rUnlock()
 	if block {
-		torrent.Add("connections firewalled", 1)
+		cl.metrics.torrent.Add("connections firewalled", 1)
 	} else {
-		torrent.Add("connections not firewalled", 1)
+		cl.metrics.torrent.Add("connections not firewalled", 1)
 	}
 	return block
 }
@@ -347,6 +326,10 @@ func (cl *Client) closeSockets() {
 // Stops the client. All connections to peers are closed and all activity will
 // come to a halt. Also clear uPnP port mappings.
 func (cl *Client) Close() {
+	// Hooks may use the Client, so they're stopped before taking the lock.
+	if cl.completionHooks != nil {
+		cl.completionHooks.close()
+	}
 	cl.lock()
 	defer cl.unlock()
 	cl.closed.Set()
@@ -369,6 +352,27 @@ func (cl *Client) ipBlockRange(ip net.IP) (r iplist.Range, blocked bool) {
 	return cl.ipBlockList.Lookup(ip)
 }
 
+// Replaces the blocklist given by ClientConfig.IPBlocklist, including for DHT servers that support
+// it, and drops connections to peers that are now blocked. A nil list blocks nothing.
+func (cl *Client) SetIPBlocklist(list iplist.Ranger) {
+	cl.lock()
+	defer cl.unlock()
+	cl.ipBlockList = list
+	cl.eachDhtServer(func(s DhtServer) {
+		if s, ok := s.(interface{ SetIPBlockList(iplist.Ranger) }); ok {
+			s.SetIPBlockList(list)
+		}
+	})
+	for _, t := range cl.torrents {
+		for c := range t.conns {
+			if r, blocked := cl.ipBlockRange(c.remoteAddr.IP); blocked {
+				cl.logger.WithDefaultLevel(log.Debug).Printf("dropping %v: blocked by %v", c, r)
+				t.dropConnection(c)
+			}
+		}
+	}
+}
+
 func (cl *Client) ipIsBlocked(ip net.IP) bool {
 	_, blocked := cl.ipBlockRange(ip)
 	return blocked
@@ -436,7 +440,7 @@ func (cl *Client) acceptConnections(l net.Listener) {
 		}
 		go func() {
 			if reject {
-				torrent.Add("rejected accepted connections", 1)
+				cl.metrics.torrent.Add("rejected accepted connections", 1)
 				cl.logger.LazyLog(log.Debug, func() log.Msg {
 					return log.Fmsg("rejecting accepted conn: %v", reject)
 				})
@@ -451,9 +455,9 @@ func (cl *Client) acceptConnections(l net.Listener) {
 					conn.RemoteAddr(),
 				)
 			})
-			torrent.Add(fmt.Sprintf("accepted conn remote IP len=%d", len(missinggo.AddrIP(conn.RemoteAddr()))), 1)
-			torrent.Add(fmt.Sprintf("accepted conn network=%s", conn.RemoteAddr().Network()), 1)
-			torrent.Add(fmt.Sprintf("accepted on %s listener", l.Addr().Network()), 1)
+			cl.metrics.torrent.Add(fmt.Sprintf("accepted conn remote IP len=%d", len(missinggo.AddrIP(conn.RemoteAddr()))), 1)
+			cl.metrics.torrent.Add(fmt.Sprintf("accepted conn network=%s", conn.RemoteAddr().Network()), 1)
+			cl.metrics.torrent.Add(fmt.Sprintf("accepted on %s listener", l.Addr().Network()), 1)
 		}()
 	}
 }
@@ -469,7 +473,7 @@ func (cl *Client) incomingConnection(nc net.Conn) {
 		defer cl.unlock()
 		c.Close()
 	}()
-	c.Discovery = peerSourceIncoming
+	c.Discovery = PeerSourceIncoming
 	cl.runReceivedConn(c)
 }
 
@@ -490,11 +494,11 @@ type dialResult struct {
 	Network string
 }
 
-func countDialResult(err error) {
+func (cl *Client) countDialResult(err error) {
 	if err == nil {
-		torrent.Add("successful dials", 1)
+		cl.metrics.torrent.Add("successful dials", 1)
 	} else {
-		torrent.Add("unsuccessful dials", 1)
+		cl.metrics.torrent.Add("unsuccessful dials", 1)
 	}
 }
 
@@ -550,7 +554,7 @@ func (cl *Client) dialFirst(ctx context.Context, addr string) dialResult {
 					if tc, ok := c.(*net.TCPConn); ok {
 						tc.SetLinger(0)
 					}
-					countDialResult(err)
+					cl.countDialResult(err)
 					dr := dialResult{c, network}
 					if c == nil {
 						if err != nil && forgettableDialError(err) {
@@ -589,7 +593,7 @@ func (cl *Client) dialFirst(ctx context.Context, addr string) dialResult {
 		}
 	}()
 	if res.Conn != nil {
-		go torrent.Add(fmt.Sprintf("network dialed first: %s", res.Conn.RemoteAddr().Network()), 1)
+		go cl.metrics.torrent.Add(fmt.Sprintf("network dialed first: %s", res.Conn.RemoteAddr().Network()), 1)
 	}
 	return res
 }
@@ -650,7 +654,7 @@ func (cl *Client) establishOutgoingConnEx(t *Torrent, addr IpPort, ctx context.C
 // Returns nil connection and nil error if no connection could be established
 // for valid reasons.
 func (cl *Client) establishOutgoingConn(t *Torrent, addr IpPort) (c *connection, err error) {
-	torrent.Add("establish outgoing connection", 1)
+	cl.metrics.torrent.Add("establish outgoing connection", 1)
 	ctx, cancel := context.WithTimeout(context.Background(), func() time.Duration {
 		cl.rLock()
 		defer cl.rUnlock()
@@ -663,7 +667,7 @@ func (cl *Client) establishOutgoingConn(t *Torrent, addr IpPort) (c *connection,
 		return
 	}
 	if c != nil {
-		torrent.Add("initiated conn with preferred header obfuscation", 1)
+		cl.metrics.torrent.Add("initiated conn with preferred header obfuscation", 1)
 		return
 	}
 	if cl.config.ForceEncryption {
@@ -677,14 +681,14 @@ func (cl *Client) establishOutgoingConn(t *Torrent, addr IpPort) (c *connection,
 	// Try again with encryption if we didn't earlier, or without if we did.
 	c, err = cl.establishOutgoingConnEx(t, addr, ctx, !obfuscatedHeaderFirst)
 	if c != nil {
-		torrent.Add("initiated conn with fallback header obfuscation", 1)
+		cl.metrics.torrent.Add("initiated conn with fallback header obfuscation", 1)
 	}
 	return
 }
 
 // Called to dial out and run a connection. The addr we're given is already
 // considered half-open.
-func (cl *Client) outgoingConnection(t *Torrent, addr IpPort, ps peerSource) {
+func (cl *Client) outgoingConnection(t *Torrent, addr IpPort, ps PeerSource) {
 	cl.dialRateLimiter.Wait(context.Background())
 	c, err := cl.establishOutgoingConn(t, addr)
 	cl.lock()
@@ -766,12 +770,12 @@ func (cl *Client) receiveHandshakes(c *connection) (t *Torrent, err error) {
 	c.setRW(rw)
 	if err == nil || err == mse.ErrNoSecretKeyMatch {
 		if c.headerEncrypted {
-			torrent.Add("handshakes received encrypted", 1)
+			cl.metrics.torrent.Add("handshakes received encrypted", 1)
 		} else {
-			torrent.Add("handshakes received unencrypted", 1)
+			cl.metrics.torrent.Add("handshakes received unencrypted", 1)
 		}
 	} else {
-		torrent.Add("handshakes received with error while handling encryption", 1)
+		cl.metrics.torrent.Add("handshakes received with error while handling encryption", 1)
 	}
 	if err != nil {
 		if err == mse.ErrNoSecretKeyMatch {
@@ -824,14 +828,14 @@ func (cl *Client) runReceivedConn(c *connection) {
 				"network", c.network,
 			)
 		})
-		torrent.Add("error receiving handshake", 1)
+		cl.metrics.torrent.Add("error receiving handshake", 1)
 		cl.lock()
 		cl.onBadAccept(c.remoteAddr)
 		cl.unlock()
 		return
 	}
 	if t == nil {
-		torrent.Add("received handshake for unloaded torrent", 1)
+		cl.metrics.torrent.Add("received handshake for unloaded torrent", 1)
 		cl.logger.LazyLog(log.Debug, func() log.Msg {
 			return log.Fmsg("received handshake for unloaded torrent")
 		})
@@ -840,7 +844,7 @@ func (cl *Client) runReceivedConn(c *connection) {
 		cl.unlock()
 		return
 	}
-	torrent.Add("received handshake for loaded torrent", 1)
+	cl.metrics.torrent.Add("received handshake for loaded torrent", 1)
 	cl.lock()
 	defer cl.unlock()
 
@@ -853,7 +857,7 @@ func (cl *Client) runHandshookConn(c *connection, t *Torrent) error {
 	c.setTorrent(t)
 	if c.PeerID == cl.peerID {
 		if c.outgoing {
-			connsToSelf.Add(1)
+			cl.metrics.connsToSelf.Add(1)
 			addr := c.conn.RemoteAddr().String()
 			cl.dopplegangerAddrs[addr] = struct{}{}
 		} else {
@@ -865,11 +869,15 @@ func (cl *Client) runHandshookConn(c *connection, t *Torrent) error {
 		cl.logger.Levelf(log.Debug, "local and remote peer ids are the same")
 		return nil
 	}
+	if p, ok := cl.rejectedPeerClient(c.PeerID); ok {
+		cl.metrics.torrent.Add("rejected peer client", 1)
+		return fmt.Errorf("peer id %+q matches rejected client pattern %q", c.PeerID[:], p)
+	}
 	c.conn.SetWriteDeadline(time.Time{})
 	c.r = deadlineReader{c.conn, c.r}
-	completedHandshakeConnectionFlags.Add(c.connectionFlags(), 1)
+	cl.metrics.completedHandshakeConnectionFlags.Add(c.connectionFlags(), 1)
 	if connIsIpv6(c.conn) {
-		torrent.Add("completed handshake over ipv6", 1)
+		cl.metrics.torrent.Add("completed handshake over ipv6", 1)
 	}
 	if err := t.addConnection(c); err != nil {
 		return fmt.Errorf("adding connection: %w", err)
@@ -1076,6 +1084,7 @@ func (cl *Client) AddTorrentInfoHashWithStorage(infoHash metainfo.Hash, specStor
 		}
 	})
 	cl.torrents[infoHash] = t
+	t.publishEvent(Event{Type: EventTorrentAdded})
 	cl.clearAcceptLimits()
 	t.updateWantPeersEvent()
 	// Tickle Client.waitAccept, new torrent may want conns.
@@ -1106,6 +1115,12 @@ func (cl *Client) AddTorrentSpec(spec *TorrentSpec) (t *Torrent, new bool, err e
 		t.setChunkSize(pp.Integer(spec.ChunkSize))
 	}
 	t.addTrackers(spec.Trackers)
+	t.addWebseeds(spec.Webseeds)
+	if spec.SelectedFiles != nil {
+		t.selectFiles(spec.SelectedFiles)
+	}
+	t.addPeerAddrs(spec.PeerAddrs)
+	t.useSources(spec.Sources)
 	t.maybeNewConns()
 	return
 }
@@ -1121,6 +1136,7 @@ func (cl *Client) dropTorrent(infoHash metainfo.Hash) (err error) {
 		panic(err)
 	}
 	delete(cl.torrents, infoHash)
+	t.publishEvent(Event{Type: EventTorrentRemoved})
 	return
 }
 
@@ -1251,7 +1267,7 @@ func (cl *Client) onDHTAnnouncePeer(ih metainfo.Hash, ip net.IP, port int, portO
 	t.addPeers([]Peer{{
 		IP:     ip,
 		Port:   port,
-		Source: peerSourceDHTAnnouncePeer,
+		Source: PeerSourceDHTAnnouncePeer,
 	}})
 }
 
@@ -0,0 +1,31 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anacrolix/torrent/iplist"
+)
+
+func TestSetIPBlocklist(t *testing.T) {
+	seeder, leecher := testSeederLeecher(t, nil)
+	requireNumPeerConns(t, seeder, 1)
+	seeder.cl.SetIPBlocklist(iplist.New([]iplist.Range{{
+		First:       net.ParseIP("127.0.0.0").To4(),
+		Last:        net.ParseIP("127.255.255.255").To4(),
+		Description: "loopback",
+	}}))
+	// Connections are dropped as the list is set.
+	assert.Empty(t, seeder.PeerConns())
+	requireNumPeerConns(t, leecher, 0)
+	leecher.AddClientPeer(seeder.cl)
+	assert.Never(t, func() bool { return len(seeder.PeerConns()) != 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	seeder.cl.SetIPBlocklist(nil)
+	leecher.AddClientPeer(seeder.cl)
+	requireNumPeerConns(t, seeder, 1)
+	requireNumPeerConns(t, leecher, 1)
+}
@@ -0,0 +1,137 @@
+// Creates a metainfo (.torrent) file from a file or directory, or prints the magnet link for it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// A flag that can be given multiple times.
+type stringsFlag []string
+
+func (me *stringsFlag) String() string {
+	return strings.Join(*me, " ")
+}
+
+func (me *stringsFlag) Set(s string) error {
+	*me = append(*me, s)
+	return nil
+}
+
+var flags = struct {
+	Trackers     stringsFlag
+	WebSeeds     stringsFlag
+	Nodes        stringsFlag
+	Private      bool
+	Source       string
+	Comment      string
+	PieceLength  string
+	PieceAligned bool
+	Output       string
+	Magnet       bool
+}{}
+
+func init() {
+	flag.Var(&flags.Trackers, "a", "tracker tier, as comma-separated announce URLs (can be repeated)")
+	flag.Var(&flags.WebSeeds, "u", "web seed URL (can be repeated)")
+	flag.Var(&flags.Nodes, "n", "DHT node as host:port (can be repeated)")
+	flag.BoolVar(&flags.Private, "private", false, "set the private flag")
+	flag.StringVar(&flags.Source, "source", "", "info source")
+	flag.StringVar(&flags.Comment, "comment", "", "metainfo comment")
+	flag.StringVar(&flags.PieceLength, "piece-length", "", "piece length, such as 256KiB (chosen from the total length by default)")
+	flag.BoolVar(&flags.PieceAligned, "piece-aligned", false, "add padding files so each file starts on a piece boundary (BEP 47)")
+	flag.StringVar(&flags.Output, "o", "", "write the metainfo to this file instead of stdout")
+	flag.BoolVar(&flags.Magnet, "magnet", false, "print the magnet link instead of writing metainfo")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <root>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := mainErr(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func mainErr(root string) error {
+	mi := metainfo.MetaInfo{}
+	mi.SetDefaults()
+	mi.Comment = flags.Comment
+	for _, tier := range flags.Trackers {
+		var urls []string
+		for _, u := range strings.Split(tier, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) != 0 {
+			mi.AnnounceList = append(mi.AnnounceList, urls)
+		}
+	}
+	if len(mi.AnnounceList) != 0 {
+		mi.Announce = mi.AnnounceList[0][0]
+	}
+	mi.UrlList = metainfo.UrlList(flags.WebSeeds)
+	for _, n := range flags.Nodes {
+		mi.Nodes = append(mi.Nodes, metainfo.Node(n))
+	}
+	info := metainfo.Info{
+		Source: flags.Source,
+	}
+	if flags.Private {
+		info.Private = &flags.Private
+	}
+	if flags.PieceLength != "" {
+		pl, err := humanize.ParseBytes(flags.PieceLength)
+		if err != nil {
+			return fmt.Errorf("parsing piece length: %w", err)
+		}
+		info.PieceLength = int64(pl)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := info.BuildFromFilePathParallel(ctx, root, metainfo.BuildFromFilePathOpts{
+		PieceAligned: flags.PieceAligned,
+	}); err != nil {
+		return fmt.Errorf("building info from %q: %w", root, err)
+	}
+	var err error
+	mi.InfoBytes, err = bencode.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("encoding info: %w", err)
+	}
+	if flags.Magnet {
+		ih := mi.HashInfoBytes()
+		_, err = fmt.Println(mi.Magnet(&ih, &info).String())
+		return err
+	}
+	if flags.Output == "" {
+		return mi.Write(os.Stdout)
+	}
+	f, err := os.Create(flags.Output)
+	if err != nil {
+		return err
+	}
+	err = mi.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,311 @@
+// Edits the parts of a metainfo (.torrent) file outside the info, such as trackers, comment and web
+// seeds. The info, and so the infohash, is never changed. Top-level fields not known to
+// metainfo.MetaInfo are kept as they are.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// A flag that can be given multiple times.
+type stringsFlag []string
+
+func (me *stringsFlag) String() string {
+	return strings.Join(*me, " ")
+}
+
+func (me *stringsFlag) Set(s string) error {
+	*me = append(*me, s)
+	return nil
+}
+
+// A string flag that records whether it was given, so it can be set to empty.
+type optionalString struct {
+	value string
+	set   bool
+}
+
+func (me *optionalString) String() string {
+	return me.value
+}
+
+func (me *optionalString) Set(s string) error {
+	me.value = s
+	me.set = true
+	return nil
+}
+
+var flags = struct {
+	AppendTiers    stringsFlag
+	AddToTier      stringsFlag
+	RemoveTrackers stringsFlag
+	ReplaceTracker stringsFlag
+	RemoveTiers    stringsFlag
+	ClearTrackers  bool
+	Comment        optionalString
+	CreatedBy      optionalString
+	AddWebSeeds    stringsFlag
+	RemoveWebSeeds stringsFlag
+	ClearWebSeeds  bool
+	StripNodes     bool
+	Output         string
+	InPlace        bool
+}{}
+
+func init() {
+	flag.BoolVar(&flags.ClearTrackers, "clear-trackers", false, "remove all trackers before applying other tracker edits")
+	flag.Var(&flags.RemoveTiers, "remove-tier", "remove the tier with this index, counting from 0 (can be repeated)")
+	flag.Var(&flags.RemoveTrackers, "remove-tracker", "remove this announce URL from every tier (can be repeated)")
+	flag.Var(&flags.ReplaceTracker, "replace-tracker", "replace announce URLs, given as old=new (can be repeated)")
+	flag.Var(&flags.AddToTier, "add-tracker", "add an announce URL to a tier, given as index=url (can be repeated)")
+	flag.Var(&flags.AppendTiers, "append-tier", "append a tier of comma-separated announce URLs (can be repeated)")
+	flag.Var(&flags.Comment, "comment", "set the comment")
+	flag.Var(&flags.CreatedBy, "created-by", "set the created by field")
+	flag.BoolVar(&flags.ClearWebSeeds, "clear-web-seeds", false, "remove all web seeds before applying other web seed edits")
+	flag.Var(&flags.RemoveWebSeeds, "remove-web-seed", "remove a web seed URL (can be repeated)")
+	flag.Var(&flags.AddWebSeeds, "add-web-seed", "add a web seed URL (can be repeated)")
+	flag.BoolVar(&flags.StripNodes, "strip-nodes", false, "remove DHT nodes")
+	flag.StringVar(&flags.Output, "o", "", "write the result to this file instead of stdout")
+	flag.BoolVar(&flags.InPlace, "w", false, "overwrite the input file with the result")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file.torrent>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Edits are applied in this order, regardless of the order given: "+
+			"-clear-trackers, -remove-tier, -remove-tracker, -replace-tracker, -add-tracker, -append-tier, "+
+			"-comment, -created-by, -clear-web-seeds, -remove-web-seed, -add-web-seed, -strip-nodes.\n")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := mainErr(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func mainErr(input string) error {
+	b, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	mi, err := metainfo.Load(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("loading %q: %w", input, err)
+	}
+	var fields map[string]bencode.Bytes
+	if err := bencode.Unmarshal(b, &fields); err != nil {
+		return fmt.Errorf("loading %q: %w", input, err)
+	}
+	infoHash := mi.HashInfoBytes()
+	if err := editTrackers(mi); err != nil {
+		return err
+	}
+	if flags.Comment.set {
+		mi.Comment = flags.Comment.value
+	}
+	if flags.CreatedBy.set {
+		mi.CreatedBy = flags.CreatedBy.value
+	}
+	if flags.ClearWebSeeds {
+		mi.UrlList = nil
+	}
+	mi.UrlList = removeStrings(mi.UrlList, flags.RemoveWebSeeds)
+	for _, ws := range flags.AddWebSeeds {
+		if !contains(mi.UrlList, ws) {
+			mi.UrlList = append(mi.UrlList, ws)
+		}
+	}
+	if flags.StripNodes {
+		mi.Nodes = nil
+	}
+	b, err = encode(mi, fields)
+	if err != nil {
+		return fmt.Errorf("encoding metainfo: %w", err)
+	}
+	// Check the result as it will be read back, rather than trusting the in-memory value.
+	check, err := metainfo.Load(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("loading edited metainfo: %w", err)
+	}
+	if check.HashInfoBytes() != infoHash {
+		return fmt.Errorf("infohash changed from %v to %v", infoHash, check.HashInfoBytes())
+	}
+	output := flags.Output
+	if flags.InPlace {
+		if output != "" {
+			return fmt.Errorf("-o and -w are mutually exclusive")
+		}
+		output = input
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return writeFile(output, b)
+}
+
+// Encodes mi, with the fields from the original file that metainfo.MetaInfo doesn't know about.
+func encode(mi *metainfo.MetaInfo, original map[string]bencode.Bytes) ([]byte, error) {
+	b, err := bencode.Marshal(mi)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]bencode.Bytes
+	if err := bencode.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	known := metaInfoKeys()
+	for k, v := range original {
+		if !known[k] {
+			fields[k] = v
+		}
+	}
+	return bencode.Marshal(fields)
+}
+
+// Returns the top-level keys that metainfo.MetaInfo encodes.
+func metaInfoKeys() map[string]bool {
+	ret := make(map[string]bool)
+	t := reflect.TypeOf(metainfo.MetaInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("bencode"), ",")
+		ret[name] = true
+	}
+	return ret
+}
+
+// Writes to a temporary file that's renamed over name, so name is never left partly written.
+func writeFile(name string, b []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func editTrackers(mi *metainfo.MetaInfo) error {
+	al := mi.UpvertedAnnounceList().Clone()
+	if flags.ClearTrackers {
+		al = nil
+	}
+	if len(flags.RemoveTiers) != 0 {
+		remove := make(map[int]bool)
+		for _, s := range flags.RemoveTiers {
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 || i >= len(al) {
+				return fmt.Errorf("bad tier index %q", s)
+			}
+			remove[i] = true
+		}
+		var kept metainfo.AnnounceList
+		for i, tier := range al {
+			if !remove[i] {
+				kept = append(kept, tier)
+			}
+		}
+		al = kept
+	}
+	for i := range al {
+		al[i] = removeStrings(al[i], flags.RemoveTrackers)
+	}
+	for _, s := range flags.ReplaceTracker {
+		from, to, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("bad tracker replacement %q, expected old=new", s)
+		}
+		for _, tier := range al {
+			for i, u := range tier {
+				if u == from {
+					tier[i] = to
+				}
+			}
+		}
+	}
+	for _, s := range flags.AddToTier {
+		index, u, ok := strings.Cut(s, "=")
+		i, err := strconv.Atoi(index)
+		if !ok || err != nil || i < 0 || i > len(al) {
+			return fmt.Errorf("bad tracker addition %q, expected index=url with index at most %d", s, len(al))
+		}
+		if i == len(al) {
+			al = append(al, nil)
+		}
+		if !contains(al[i], u) {
+			al[i] = append(al[i], u)
+		}
+	}
+	for _, s := range flags.AppendTiers {
+		var tier []string
+		for _, u := range strings.Split(s, ",") {
+			if u = strings.TrimSpace(u); u != "" && !contains(tier, u) {
+				tier = append(tier, u)
+			}
+		}
+		if len(tier) != 0 {
+			al = append(al, tier)
+		}
+	}
+	// Drop tiers left empty by removals.
+	mi.AnnounceList = nil
+	for _, tier := range al {
+		if len(tier) != 0 {
+			mi.AnnounceList = append(mi.AnnounceList, tier)
+		}
+	}
+	mi.Announce = ""
+	if len(mi.AnnounceList) != 0 {
+		mi.Announce = mi.AnnounceList[0][0]
+	}
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, s1 := range ss {
+		if s1 == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeStrings(ss, remove []string) (ret []string) {
+	for _, s := range ss {
+		if !contains(remove, s) {
+			ret = append(ret, s)
+		}
+	}
+	return
+}
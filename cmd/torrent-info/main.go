@@ -0,0 +1,225 @@
+// Prints information about a metainfo (.torrent) file or magnet link.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+var flags = struct {
+	JSON bool
+}{}
+
+func init() {
+	flag.BoolVar(&flags.JSON, "json", false, "print JSON instead of text")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file.torrent|magnet:...>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+// The output for a torrent. Fields that aren't known, such as the info for a magnet link, are
+// omitted.
+type torrentInfo struct {
+	InfoHash     string     `json:"infoHash"`
+	Name         string     `json:"name,omitempty"`
+	PieceLength  int64      `json:"pieceLength,omitempty"`
+	NumPieces    int        `json:"numPieces,omitempty"`
+	TotalLength  int64      `json:"totalLength,omitempty"`
+	Private      *bool      `json:"private,omitempty"`
+	Source       string     `json:"source,omitempty"`
+	Files        []fileInfo `json:"files,omitempty"`
+	Trackers     [][]string `json:"trackers,omitempty"`
+	WebSeeds     []string   `json:"webSeeds,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+	CreatedBy    string     `json:"createdBy,omitempty"`
+	CreationDate *time.Time `json:"creationDate,omitempty"`
+	// Whether the info was available, rather than just a magnet link.
+	haveInfo bool
+}
+
+type fileInfo struct {
+	Path   []string `json:"path"`
+	Length int64    `json:"length"`
+	// The range of pieces [FirstPiece, EndPiece) containing the file's data.
+	FirstPiece int  `json:"firstPiece"`
+	EndPiece   int  `json:"endPiece"`
+	Padding    bool `json:"padding,omitempty"`
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	ti, err := load(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flags.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(ti)
+	} else {
+		err = ti.write(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func load(arg string) (ti torrentInfo, err error) {
+	if strings.HasPrefix(arg, "magnet:") {
+		var m metainfo.Magnet
+		m, err = metainfo.ParseMagnetUri(arg)
+		if err != nil {
+			return
+		}
+		ti.InfoHash = m.InfoHash.HexString()
+		ti.Name = m.DisplayName
+		if len(m.Trackers) != 0 {
+			ti.Trackers = [][]string{m.Trackers}
+		}
+		ti.WebSeeds = m.WebSeeds
+		return
+	}
+	mi, err := metainfo.LoadFromFile(arg)
+	if err != nil {
+		return
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		err = fmt.Errorf("unmarshalling info: %w", err)
+		return
+	}
+	if err = info.Validate(); err != nil {
+		err = fmt.Errorf("invalid info: %w", err)
+		return
+	}
+	ti.haveInfo = true
+	ti.InfoHash = mi.HashInfoBytes().HexString()
+	ti.Name = info.BestName()
+	ti.PieceLength = info.PieceLength
+	ti.NumPieces = info.NumPieces()
+	ti.TotalLength = info.TotalLength()
+	private := info.Private != nil && *info.Private
+	ti.Private = &private
+	ti.Source = info.Source
+	var offset int64
+	for _, fi := range info.UpvertedFiles() {
+		f := fileInfo{
+			Path:    fi.BestPath(),
+			Length:  fi.Length,
+			Padding: fi.IsPad(),
+		}
+		if !info.IsDir() {
+			f.Path = []string{info.BestName()}
+		}
+		if info.PieceLength != 0 {
+			f.FirstPiece = int(offset / info.PieceLength)
+			f.EndPiece = int((offset + fi.Length + info.PieceLength - 1) / info.PieceLength)
+		}
+		ti.Files = append(ti.Files, f)
+		offset += fi.Length
+	}
+	ti.Trackers = mi.UpvertedAnnounceList()
+	ti.WebSeeds = mi.UrlList
+	ti.Comment = mi.Comment
+	ti.CreatedBy = mi.CreatedBy
+	if mi.CreationDate != 0 {
+		t := time.Unix(mi.CreationDate, 0).UTC()
+		ti.CreationDate = &t
+	}
+	return
+}
+
+func (ti torrentInfo) write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Info hash:    %s\n", ti.InfoHash)
+	if ti.Name != "" {
+		fmt.Fprintf(&b, "Name:         %s\n", ti.Name)
+	}
+	if ti.haveInfo {
+		fmt.Fprintf(&b, "Total length: %s (%d bytes)\n", humanize.IBytes(uint64(ti.TotalLength)), ti.TotalLength)
+		fmt.Fprintf(&b, "Piece length: %s\n", humanize.IBytes(uint64(ti.PieceLength)))
+		fmt.Fprintf(&b, "Pieces:       %d\n", ti.NumPieces)
+		fmt.Fprintf(&b, "Private:      %v\n", *ti.Private)
+	}
+	if ti.Source != "" {
+		fmt.Fprintf(&b, "Source:       %s\n", ti.Source)
+	}
+	if ti.Comment != "" {
+		fmt.Fprintf(&b, "Comment:      %s\n", ti.Comment)
+	}
+	if ti.CreatedBy != "" {
+		fmt.Fprintf(&b, "Created by:   %s\n", ti.CreatedBy)
+	}
+	if ti.CreationDate != nil {
+		fmt.Fprintf(&b, "Created:      %s\n", ti.CreationDate.Format(time.RFC3339))
+	}
+	if len(ti.Trackers) != 0 {
+		fmt.Fprintf(&b, "Trackers:\n")
+		for i, tier := range ti.Trackers {
+			fmt.Fprintf(&b, "  tier %d:\n", i)
+			for _, u := range tier {
+				fmt.Fprintf(&b, "    %s\n", u)
+			}
+		}
+	}
+	if len(ti.WebSeeds) != 0 {
+		fmt.Fprintf(&b, "Web seeds:\n")
+		for _, u := range ti.WebSeeds {
+			fmt.Fprintf(&b, "  %s\n", u)
+		}
+	}
+	if len(ti.Files) != 0 {
+		fmt.Fprintf(&b, "Files:\n")
+		writeTree(&b, ti.Files)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Prints files as a tree, indenting each directory level. Directories are listed before the files
+// that they contain.
+func writeTree(b *strings.Builder, files []fileInfo) {
+	sorted := append([]fileInfo(nil), files...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.Join(sorted[i].Path, "/") < strings.Join(sorted[j].Path, "/")
+	})
+	var dir []string
+	for _, f := range sorted {
+		parent := f.Path[:len(f.Path)-1]
+		common := 0
+		for common < len(dir) && common < len(parent) && dir[common] == parent[common] {
+			common++
+		}
+		for i := common; i < len(parent); i++ {
+			fmt.Fprintf(b, "  %s%s/\n", strings.Repeat("  ", i), parent[i])
+		}
+		dir = parent
+		pieces := "no pieces"
+		if f.EndPiece > f.FirstPiece {
+			pieces = fmt.Sprintf("pieces %d-%d", f.FirstPiece, f.EndPiece-1)
+		}
+		if f.Padding {
+			pieces += " (padding)"
+		}
+		fmt.Fprintf(b, "  %s%s  %s  %s\n",
+			strings.Repeat("  ", len(parent)), f.Path[len(f.Path)-1],
+			humanize.IBytes(uint64(f.Length)), pieces)
+	}
+}
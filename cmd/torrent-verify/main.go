@@ -0,0 +1,221 @@
+// Checks torrent data on disk against a metainfo (.torrent) file, without a Client or any network
+// access. Data is expected where file storage would put it. Exits with status 1 if the data is
+// incomplete.
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+var flags = struct {
+	JSON    bool
+	Workers int
+}{}
+
+func init() {
+	flag.BoolVar(&flags.JSON, "json", false, "print JSON instead of text")
+	flag.IntVar(&flags.Workers, "workers", runtime.NumCPU(), "pieces hashed concurrently")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file.torrent> <data dir>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "The data dir is the directory that contains the torrent's files or directory.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Files are looked for at the sanitized paths current file storage uses. Data saved by older "+
+			"versions at paths taken unchanged from the metainfo, where those differ, is reported missing.\n")
+		flag.PrintDefaults()
+	}
+}
+
+type fileResult struct {
+	Path   string `json:"path"`
+	Length int64  `json:"length"`
+	// Bytes of the file in pieces that passed verification.
+	BytesComplete int64   `json:"bytesComplete"`
+	Percent       float64 `json:"percent"`
+	Missing       bool    `json:"missing,omitempty"`
+	// The size on disk, if it differs from Length.
+	WrongSize *int64 `json:"wrongSize,omitempty"`
+}
+
+type result struct {
+	InfoHash  string       `json:"infoHash"`
+	NumPieces int          `json:"numPieces"`
+	BadPieces []int        `json:"badPieces,omitempty"`
+	Files     []fileResult `json:"files"`
+	Complete  bool         `json:"complete"`
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	res, err := verify(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flags.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+	} else {
+		err = res.write(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !res.Complete {
+		os.Exit(1)
+	}
+}
+
+func verify(torrentFile, dataDir string) (res result, err error) {
+	mi, err := metainfo.LoadFromFile(torrentFile)
+	if err != nil {
+		return
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		err = fmt.Errorf("unmarshalling info: %w", err)
+		return
+	}
+	if err = info.Validate(); err != nil {
+		err = fmt.Errorf("invalid info: %w", err)
+		return
+	}
+	infoHash := mi.HashInfoBytes()
+	ci := storage.NewFile(dataDir)
+	defer ci.Close()
+	t, err := storage.NewClient(ci).OpenTorrent(&info, infoHash)
+	if err != nil {
+		return
+	}
+	defer t.Close()
+	res.InfoHash = infoHash.HexString()
+	res.NumPieces = info.NumPieces()
+	good := checkPieces(&info, t)
+	for i, ok := range good {
+		if !ok {
+			res.BadPieces = append(res.BadPieces, i)
+		}
+	}
+	paths := storage.FilePaths(dataDir, &info)
+	var offset int64
+	for i, fi := range info.UpvertedFiles() {
+		begin := offset
+		offset += fi.Length
+		if fi.IsPad() {
+			continue
+		}
+		fr := fileResult{
+			Path:   paths[i],
+			Length: fi.Length,
+		}
+		// Empty files have no data to check, and not every client creates them.
+		if st, err := os.Stat(paths[i]); err != nil {
+			fr.Missing = fi.Length != 0
+		} else if st.Size() != fi.Length {
+			size := st.Size()
+			fr.WrongSize = &size
+		}
+		fr.BytesComplete = bytesComplete(&info, good, begin, fi.Length)
+		fr.Percent = 100
+		if fi.Length != 0 {
+			fr.Percent = 100 * float64(fr.BytesComplete) / float64(fi.Length)
+		}
+		res.Files = append(res.Files, fr)
+	}
+	res.Complete = len(res.BadPieces) == 0
+	for _, fr := range res.Files {
+		if fr.Missing || fr.WrongSize != nil {
+			res.Complete = false
+		}
+	}
+	return
+}
+
+// Hashes every piece, returning whether each matched.
+func checkPieces(info *metainfo.Info, t *storage.Torrent) []bool {
+	good := make([]bool, info.NumPieces())
+	pieces := make(chan int)
+	var wg sync.WaitGroup
+	workers := flags.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pieces {
+				p := info.Piece(i)
+				h := sha1.New()
+				n, _ := io.Copy(h, io.NewSectionReader(t.Piece(p), 0, p.Length()))
+				if n == p.Length() {
+					hash := p.Hash()
+					good[i] = bytes.Equal(h.Sum(nil), hash[:])
+				}
+			}
+		}()
+	}
+	for i := range good {
+		pieces <- i
+	}
+	close(pieces)
+	wg.Wait()
+	return good
+}
+
+// The bytes in [begin, begin+length) of the torrent that lie in good pieces.
+func bytesComplete(info *metainfo.Info, good []bool, begin, length int64) (ret int64) {
+	end := begin + length
+	for off := begin; off < end; {
+		piece := int(off / info.PieceLength)
+		pieceEnd := int64(piece+1) * info.PieceLength
+		if pieceEnd > end {
+			pieceEnd = end
+		}
+		if good[piece] {
+			ret += pieceEnd - off
+		}
+		off = pieceEnd
+	}
+	return
+}
+
+func (res result) write(w io.Writer) error {
+	var b bytes.Buffer
+	for _, fr := range res.Files {
+		var problem string
+		switch {
+		case fr.Missing:
+			problem = "  missing"
+		case fr.WrongSize != nil:
+			problem = fmt.Sprintf("  wrong size %d", *fr.WrongSize)
+		}
+		fmt.Fprintf(&b, "%6.2f%%  %s%s\n", fr.Percent, fr.Path, problem)
+	}
+	fmt.Fprintf(&b, "%d/%d pieces good\n", res.NumPieces-len(res.BadPieces), res.NumPieces)
+	if len(res.BadPieces) != 0 {
+		fmt.Fprintf(&b, "bad pieces: %v\n", res.BadPieces)
+	}
+	if res.Complete {
+		fmt.Fprintf(&b, "complete\n")
+	} else {
+		fmt.Fprintf(&b, "incomplete\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,301 @@
+// Downloads torrents from the command-line.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/time/rate"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// A flag that can be given multiple times.
+type stringsFlag []string
+
+func (me *stringsFlag) String() string {
+	return strings.Join(*me, " ")
+}
+
+func (me *stringsFlag) Set(s string) error {
+	*me = append(*me, s)
+	return nil
+}
+
+var flags = struct {
+	Files           stringsFlag
+	DataDir         string
+	Addr            string
+	Seed            bool
+	UploadRate      string
+	DownloadRate    string
+	DisableDHT      bool
+	DisableTrackers bool
+	DisablePEX      bool
+	NoProgress      bool
+	Debug           bool
+}{}
+
+func init() {
+	flag.Var(&flags.Files, "file", "only download files with this index, or with paths matching this glob (can be repeated). Indexes skip padding files, unlike BEP 53 magnet so= indexes")
+	flag.StringVar(&flags.DataDir, "data-dir", ".", "directory to store downloaded data")
+	flag.StringVar(&flags.Addr, "addr", "", "address to listen for peers on, such as :42069")
+	flag.BoolVar(&flags.Seed, "seed", false, "keep seeding after the downloads complete")
+	flag.StringVar(&flags.UploadRate, "upload-rate", "", "upload rate limit per second, such as 1MB")
+	flag.StringVar(&flags.DownloadRate, "download-rate", "", "download rate limit per second, such as 1MB")
+	flag.BoolVar(&flags.DisableDHT, "disable-dht", false, "don't use DHT to find peers")
+	flag.BoolVar(&flags.DisableTrackers, "disable-trackers", false, "don't announce to trackers")
+	flag.BoolVar(&flags.DisablePEX, "disable-pex", false, "don't exchange peers with other peers")
+	flag.BoolVar(&flags.NoProgress, "no-progress", false, "don't print progress")
+	flag.BoolVar(&flags.Debug, "debug", false, "enable client debugging")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <magnet link or .torrent file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := mainErr(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newLimiter(s string) (*rate.Limiter, error) {
+	if s == "" {
+		return rate.NewLimiter(rate.Inf, 0), nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return nil, err
+	}
+	// The burst must fit the largest chunk or read.
+	return rate.NewLimiter(rate.Limit(n), 1<<20), nil
+}
+
+func clientConfig() (*torrent.ClientConfig, error) {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = flags.DataDir
+	cfg.DefaultStorage = storage.NewFile(flags.DataDir)
+	cfg.Seed = flags.Seed
+	cfg.NoDHT = flags.DisableDHT
+	cfg.DisableTrackers = flags.DisableTrackers
+	cfg.DisablePEX = flags.DisablePEX
+	cfg.Debug = flags.Debug
+	if flags.Addr != "" {
+		cfg.SetListenAddr(flags.Addr)
+	}
+	var err error
+	cfg.UploadRateLimiter, err = newLimiter(flags.UploadRate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing upload rate: %w", err)
+	}
+	cfg.DownloadRateLimiter, err = newLimiter(flags.DownloadRate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing download rate: %w", err)
+	}
+	return cfg, nil
+}
+
+func mainErr() error {
+	cfg, err := clientConfig()
+	if err != nil {
+		return err
+	}
+	defer cfg.DefaultStorage.Close()
+	cl, err := torrent.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	defer cl.Close()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	go func() {
+		<-interrupted
+		log.Print("interrupted, closing client")
+		cl.Close()
+	}()
+	var ts []*torrent.Torrent
+	for _, arg := range flag.Args() {
+		t, err := addTorrent(cl, arg)
+		if err != nil {
+			return fmt.Errorf("adding %q: %w", arg, err)
+		}
+		ts = append(ts, t)
+	}
+	var wg sync.WaitGroup
+	for _, t := range ts {
+		wg.Add(1)
+		go func(t *torrent.Torrent) {
+			defer wg.Done()
+			select {
+			case <-t.GotInfo():
+			case <-cl.Closed():
+				return
+			}
+			if err := selectFiles(t); err != nil {
+				log.Printf("%s: %s", t.Name(), err)
+				cl.Close()
+			}
+		}(t)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		waitComplete(cl, ts)
+		close(done)
+	}()
+	if !flags.NoProgress {
+		go progress(cl, ts, done)
+	}
+	select {
+	case <-done:
+	case <-cl.Closed():
+		return fmt.Errorf("client closed before downloads completed")
+	}
+	log.Print("downloaded all the torrents")
+	if flags.Seed {
+		log.Print("seeding")
+		<-cl.Closed()
+	}
+	return nil
+}
+
+func addTorrent(cl *torrent.Client, arg string) (*torrent.Torrent, error) {
+	if strings.HasPrefix(arg, "magnet:") {
+		return cl.AddMagnet(arg)
+	}
+	return cl.AddTorrentFromFile(arg)
+}
+
+// Marks the files chosen with -file for download, or everything if none were given.
+func selectFiles(t *torrent.Torrent) error {
+	files := t.Files()
+	if len(flags.Files) == 0 {
+		for _, f := range files {
+			f.Download()
+		}
+		return nil
+	}
+	matched := false
+	for _, sel := range flags.Files {
+		if i, err := strconv.Atoi(sel); err == nil {
+			if i < 0 || i >= len(files) {
+				return fmt.Errorf("file index %d out of range", i)
+			}
+			files[i].Download()
+			matched = true
+			continue
+		}
+		for _, f := range files {
+			displayMatch, err := path.Match(sel, f.DisplayPath())
+			if err != nil {
+				return fmt.Errorf("bad file pattern %q: %w", sel, err)
+			}
+			pathMatch, _ := path.Match(sel, f.Path())
+			if displayMatch || pathMatch {
+				f.Download()
+				matched = true
+			}
+		}
+	}
+	if !matched {
+		return fmt.Errorf("no files selected")
+	}
+	return nil
+}
+
+// The bytes of files selected for download, and how many of them are complete.
+func wantedProgress(t *torrent.Torrent) (completed, wanted int64) {
+	for _, f := range t.Files() {
+		if f.Priority() == torrent.PiecePriorityNone {
+			continue
+		}
+		wanted += f.Length()
+		for _, ps := range f.State() {
+			if ps.Complete {
+				completed += ps.Bytes
+			}
+		}
+	}
+	return
+}
+
+func waitComplete(cl *torrent.Client, ts []*torrent.Torrent) {
+	for {
+		all := true
+		for _, t := range ts {
+			if t.Info() == nil {
+				all = false
+				break
+			}
+			completed, wanted := wantedProgress(t)
+			if completed < wanted {
+				all = false
+				break
+			}
+		}
+		if all {
+			return
+		}
+		select {
+		case <-cl.Closed():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func progress(cl *torrent.Client, ts []*torrent.Torrent, done <-chan struct{}) {
+	lastRead := make(map[*torrent.Torrent]int64)
+	lastTime := time.Now()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-cl.Closed():
+			return
+		case now := <-tick.C:
+			elapsed := now.Sub(lastTime).Seconds()
+			lastTime = now
+			for _, t := range ts {
+				stats := t.Stats()
+				read := stats.BytesReadUsefulData.Int64()
+				readRate := float64(read-lastRead[t]) / elapsed
+				lastRead[t] = read
+				if t.Info() == nil {
+					log.Printf("%s: getting info, %d peers", t.Name(), stats.ActivePeers)
+					continue
+				}
+				completed, wanted := wantedProgress(t)
+				percent := 100.0
+				if wanted != 0 {
+					percent = 100 * float64(completed) / float64(wanted)
+				}
+				log.Printf("%s: %s/%s (%.1f%%), total %s/%s, %s/s, %d/%d peers",
+					t.Name(),
+					humanize.IBytes(uint64(completed)), humanize.IBytes(uint64(wanted)), percent,
+					humanize.IBytes(uint64(t.BytesCompleted())), humanize.IBytes(uint64(t.Length())),
+					humanize.IBytes(uint64(readRate)),
+					stats.ActivePeers, stats.TotalPeers)
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Owns the Client and the persisted session. Torrents are paused by dropping them from the Client
+// while keeping them in the session, and resumed by adding them again.
+type daemon struct {
+	cl       *torrent.Client
+	stateDir string
+
+	mu      sync.Mutex
+	session session
+}
+
+var errUnknownTorrent = errors.New("unknown torrent")
+
+func newDaemon(cl *torrent.Client, stateDir string) (*daemon, error) {
+	s, err := loadSession(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading session: %w", err)
+	}
+	d := &daemon{
+		cl:       cl,
+		stateDir: stateDir,
+		session:  s,
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for hex, st := range d.session.Torrents {
+		if st.Paused {
+			continue
+		}
+		var ih metainfo.Hash
+		if err := ih.FromHexString(hex); err != nil {
+			log.Printf("bad infohash %q in session: %s", hex, err)
+			continue
+		}
+		if _, err := d.start(ih, st); err != nil {
+			log.Printf("error restoring torrent %s: %s", hex, err)
+		}
+	}
+	return d, nil
+}
+
+func (d *daemon) saveSession() {
+	if err := d.session.save(d.stateDir); err != nil {
+		log.Printf("error saving session: %s", err)
+	}
+}
+
+// Adds the torrent to the Client, from its saved metainfo if there is any.
+func (d *daemon) start(ih metainfo.Hash, st *sessionTorrent) (t *torrent.Torrent, err error) {
+	mi, err := metainfo.LoadFromFile(metainfoPath(d.stateDir, ih))
+	switch {
+	case err == nil:
+		t, err = d.cl.AddTorrent(mi)
+	case os.IsNotExist(err) && st.Magnet != "":
+		t, err = d.cl.AddMagnet(st.Magnet)
+	}
+	if err != nil {
+		return
+	}
+	go d.onInfo(t)
+	return
+}
+
+// Saves the metainfo and applies the file priorities once the info is available.
+func (d *daemon) onInfo(t *torrent.Torrent) {
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return
+	}
+	ih := t.InfoHash()
+	if _, err := os.Stat(metainfoPath(d.stateDir, ih)); os.IsNotExist(err) {
+		if err := saveMetainfo(d.stateDir, t.Metainfo()); err != nil {
+			log.Printf("error saving metainfo for %s: %s", ih.HexString(), err)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.session.Torrents[ih.HexString()]
+	if !ok {
+		return
+	}
+	for i, f := range t.Files() {
+		set, err := parsePriority(st.FilePriorities[i])
+		if err != nil {
+			log.Printf("%s: file %d: %s", ih.HexString(), i, err)
+			continue
+		}
+		set(f)
+	}
+}
+
+// Adds a torrent from a magnet link or metainfo. Adding a torrent that's already in the session has
+// no effect.
+func (d *daemon) add(magnet string, mi *metainfo.MetaInfo, watched bool) (ih metainfo.Hash, err error) {
+	if mi != nil {
+		ih = mi.HashInfoBytes()
+	} else {
+		var m metainfo.Magnet
+		m, err = metainfo.ParseMagnetUri(magnet)
+		if err != nil {
+			return
+		}
+		ih = m.InfoHash
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.session.Torrents[ih.HexString()]; ok {
+		return
+	}
+	if mi != nil {
+		if err = saveMetainfo(d.stateDir, *mi); err != nil {
+			return
+		}
+	}
+	st := &sessionTorrent{
+		Magnet:  magnet,
+		Watched: watched,
+	}
+	if _, err = d.start(ih, st); err != nil {
+		return
+	}
+	d.session.Torrents[ih.HexString()] = st
+	d.saveSession()
+	return
+}
+
+func (d *daemon) sessionTorrent(ih metainfo.Hash) (*sessionTorrent, error) {
+	st, ok := d.session.Torrents[ih.HexString()]
+	if !ok {
+		return nil, errUnknownTorrent
+	}
+	return st, nil
+}
+
+// Removes the torrent from the session and Client. Downloaded data is kept.
+func (d *daemon) remove(ih metainfo.Hash) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.sessionTorrent(ih); err != nil {
+		return err
+	}
+	delete(d.session.Torrents, ih.HexString())
+	if t, ok := d.cl.Torrent(ih); ok {
+		t.Drop()
+	}
+	if err := os.Remove(metainfoPath(d.stateDir, ih)); err != nil && !os.IsNotExist(err) {
+		log.Printf("error removing metainfo: %s", err)
+	}
+	d.saveSession()
+	return nil
+}
+
+func (d *daemon) setPaused(ih metainfo.Hash, paused bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, err := d.sessionTorrent(ih)
+	if err != nil {
+		return err
+	}
+	if st.Paused == paused {
+		return nil
+	}
+	if paused {
+		if t, ok := d.cl.Torrent(ih); ok {
+			t.Drop()
+		}
+	} else if _, err := d.start(ih, st); err != nil {
+		return err
+	}
+	st.Paused = paused
+	d.saveSession()
+	return nil
+}
+
+func (d *daemon) setFilePriority(ih metainfo.Hash, index int, prio string) error {
+	set, err := parsePriority(prio)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, err := d.sessionTorrent(ih)
+	if err != nil {
+		return err
+	}
+	if t, ok := d.cl.Torrent(ih); ok && t.Info() != nil {
+		files := t.Files()
+		if index < 0 || index >= len(files) {
+			return fmt.Errorf("file index %d out of range", index)
+		}
+		set(files[index])
+	}
+	if prio == "normal" || prio == "" {
+		delete(st.FilePriorities, index)
+	} else {
+		if st.FilePriorities == nil {
+			st.FilePriorities = make(map[int]string)
+		}
+		st.FilePriorities[index] = prio
+	}
+	d.saveSession()
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/internal/testutil"
+	"github.com/anacrolix/torrent/storage"
+)
+
+func newTestClient(t *testing.T) *torrent.Client {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.DefaultStorage = storage.NewFile(cfg.DataDir)
+	t.Cleanup(func() { cfg.DefaultStorage.Close() })
+	cl, err := torrent.NewClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cl.Close() })
+	return cl
+}
+
+func TestDaemonAddPauseRemove(t *testing.T) {
+	cl := newTestClient(t)
+	d, err := newDaemon(cl, t.TempDir())
+	require.NoError(t, err)
+	mi := testutil.GreetingMetaInfo()
+	ih, err := d.add("", mi, false)
+	require.NoError(t, err)
+	assert.Equal(t, mi.HashInfoBytes(), ih)
+	_, ok := cl.Torrent(ih)
+	assert.True(t, ok)
+	assert.FileExists(t, metainfoPath(d.stateDir, ih))
+	// Adding again has no effect.
+	_, err = d.add("", mi, true)
+	require.NoError(t, err)
+	assert.False(t, d.session.Torrents[ih.HexString()].Watched)
+
+	require.NoError(t, d.setPaused(ih, true))
+	_, ok = cl.Torrent(ih)
+	assert.False(t, ok)
+	assert.True(t, d.session.Torrents[ih.HexString()].Paused)
+	require.NoError(t, d.setPaused(ih, false))
+	_, ok = cl.Torrent(ih)
+	assert.True(t, ok)
+	assert.False(t, d.session.Torrents[ih.HexString()].Paused)
+
+	require.NoError(t, d.remove(ih))
+	_, ok = cl.Torrent(ih)
+	assert.False(t, ok)
+	assert.Empty(t, d.session.Torrents)
+	assert.NoFileExists(t, metainfoPath(d.stateDir, ih))
+	assert.ErrorIs(t, d.remove(ih), errUnknownTorrent)
+	assert.ErrorIs(t, d.setPaused(ih, true), errUnknownTorrent)
+}
+
+func TestDaemonRestoresSession(t *testing.T) {
+	stateDir := t.TempDir()
+	d, err := newDaemon(newTestClient(t), stateDir)
+	require.NoError(t, err)
+	greeting, err := d.add("", testutil.GreetingMetaInfo(), false)
+	require.NoError(t, err)
+	require.NoError(t, d.setFilePriority(greeting, 0, "high"))
+	other := testutil.Torrent{Name: "other", Files: []testutil.File{{Data: "other"}}}
+	paused, err := d.add("", other.Metainfo(5), true)
+	require.NoError(t, err)
+	require.NoError(t, d.setPaused(paused, true))
+	fromMagnet, err := d.add("magnet:?xt=urn:btih:"+strings.Repeat("ab", 20), nil, false)
+	require.NoError(t, err)
+
+	s, err := loadSession(stateDir)
+	require.NoError(t, err)
+	assert.Equal(t, d.session, s)
+
+	cl := newTestClient(t)
+	d, err = newDaemon(cl, stateDir)
+	require.NoError(t, err)
+	assert.Equal(t, s, d.session)
+	assert.True(t, d.session.Torrents[paused.HexString()].Watched)
+	_, ok := cl.Torrent(paused)
+	assert.False(t, ok)
+	_, ok = cl.Torrent(fromMagnet)
+	assert.True(t, ok)
+	tor, ok := cl.Torrent(greeting)
+	require.True(t, ok)
+	// Priorities are applied once the info is available, which it is immediately from the saved
+	// metainfo, but asynchronously.
+	require.Eventually(t, func() bool {
+		return priorityName(tor.Files()[0]) == "high"
+	}, time.Second, time.Millisecond)
+	require.NoError(t, d.setPaused(paused, false))
+	_, ok = cl.Torrent(paused)
+	assert.True(t, ok)
+}
@@ -0,0 +1,198 @@
+// A long-running torrent daemon. It persists its torrents in a state directory, optionally adds and
+// removes torrents as .torrent and .magnet files appear in a watched directory, and is controlled
+// with JSON-RPC over HTTP and a Unix socket. See Service for the methods.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"github.com/anacrolix/torrent/util/dirwatch"
+)
+
+var flags = struct {
+	DataDir   string
+	StateDir  string
+	WatchDir  string
+	Addr      string
+	HTTPAddr  string
+	Socket    string
+	Seed      bool
+	Blocklist string
+}{}
+
+func init() {
+	flag.StringVar(&flags.DataDir, "data-dir", ".", "directory to store downloaded data")
+	flag.StringVar(&flags.StateDir, "state-dir", ".torrentd", "directory to persist the session in")
+	flag.StringVar(&flags.WatchDir, "watch-dir", "", "add torrents for .torrent and .magnet files in this directory")
+	flag.StringVar(&flags.Addr, "addr", "", "address to listen for peers on, such as :42069")
+	flag.StringVar(&flags.HTTPAddr, "http", "localhost:9092", "address to serve JSON-RPC over HTTP on, or empty to disable")
+	flag.StringVar(&flags.Socket, "socket", "", "path of a Unix socket to serve JSON-RPC on")
+	flag.BoolVar(&flags.Seed, "seed", true, "seed completed torrents")
+	flag.StringVar(&flags.Blocklist, "blocklist", "", "comma-separated IP blocklist files, reloaded on SIGHUP")
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	flag.Parse()
+	if err := mainErr(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func mainErr() error {
+	if err := os.MkdirAll(flags.StateDir, 0o755); err != nil {
+		return err
+	}
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = flags.DataDir
+	cfg.DefaultStorage = storage.NewFile(flags.DataDir)
+	defer cfg.DefaultStorage.Close()
+	cfg.Seed = flags.Seed
+	if flags.Addr != "" {
+		cfg.SetListenAddr(flags.Addr)
+	}
+	if flags.Blocklist != "" {
+		bl, err := loadBlocklist()
+		if err != nil {
+			return err
+		}
+		cfg.IPBlocklist = bl
+	}
+	cl, err := torrent.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	defer cl.Close()
+	d, err := newDaemon(cl, flags.StateDir)
+	if err != nil {
+		return err
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("Torrentd", &Service{d}); err != nil {
+		return err
+	}
+	if flags.HTTPAddr != "" {
+		l, err := net.Listen("tcp", flags.HTTPAddr)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/rpc", rpcHandler(server))
+		mux.Handle("/metrics", cl.PrometheusHandler())
+		mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("format") == "json" {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(cl.Status())
+				return
+			}
+			cl.WriteStatus(w)
+		})
+		log.Printf("serving JSON-RPC at http://%s/rpc", l.Addr())
+		go func() {
+			if err := http.Serve(l, mux); err != nil {
+				log.Printf("http server stopped: %s", err)
+			}
+		}()
+	}
+	if flags.Socket != "" {
+		// Remove a socket left behind by a previous run.
+		if err := os.Remove(flags.Socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		l, err := net.Listen("unix", flags.Socket)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		go serveUnix(server, l)
+	}
+	if flags.WatchDir != "" {
+		dw, err := dirwatch.New(flags.WatchDir)
+		if err != nil {
+			return fmt.Errorf("watching %q: %w", flags.WatchDir, err)
+		}
+		defer dw.Close()
+		go watch(d, dw)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				reloadBlocklist(cl)
+				continue
+			}
+			log.Printf("received %v, shutting down", sig)
+		case <-cl.Closed():
+		}
+		return nil
+	}
+}
+
+func loadBlocklist() (*iplist.IPList, error) {
+	bl, err := iplist.LoadFiles(strings.Split(flags.Blocklist, ",")...)
+	if err != nil {
+		return nil, fmt.Errorf("loading blocklist: %w", err)
+	}
+	log.Printf("loaded blocklist with %d ranges", bl.NumRanges())
+	return bl, nil
+}
+
+// Keeps the current blocklist if the new one can't be loaded.
+func reloadBlocklist(cl *torrent.Client) {
+	if flags.Blocklist == "" {
+		return
+	}
+	bl, err := loadBlocklist()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	cl.SetIPBlocklist(bl)
+}
+
+func watch(d *daemon, dw *dirwatch.Instance) {
+	for e := range dw.Events {
+		switch e.Change {
+		case dirwatch.Added:
+			var mi *metainfo.MetaInfo
+			if e.TorrentFilePath != "" {
+				var err error
+				mi, err = metainfo.LoadFromFile(e.TorrentFilePath)
+				if err != nil {
+					log.Printf("error loading %q: %s", e.TorrentFilePath, err)
+					continue
+				}
+			}
+			if _, err := d.add(e.MagnetURI, mi, true); err != nil {
+				log.Printf("error adding watched torrent %s: %s", e.InfoHash.HexString(), err)
+			}
+		case dirwatch.Removed:
+			d.mu.Lock()
+			st, ok := d.session.Torrents[e.InfoHash.HexString()]
+			watched := ok && st.Watched
+			d.mu.Unlock()
+			if watched {
+				if err := d.remove(e.InfoHash); err != nil {
+					log.Printf("error removing watched torrent %s: %s", e.InfoHash.HexString(), err)
+				}
+			}
+		}
+	}
+}
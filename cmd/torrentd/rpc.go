@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sort"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// The JSON-RPC service, registered as "Torrentd". Methods are called like "Torrentd.List".
+type Service struct {
+	d *daemon
+}
+
+type AddArgs struct {
+	// A magnet link.
+	Magnet string
+	// Bencoded metainfo. This is base64 in JSON.
+	Metainfo []byte
+}
+
+type InfoHashArgs struct {
+	InfoHash metainfo.Hash
+}
+
+type SetFilePriorityArgs struct {
+	InfoHash metainfo.Hash
+	// Index into the torrent's files.
+	File int
+	// One of "none", "normal" or "high".
+	Priority string
+}
+
+type TorrentStatus struct {
+	InfoHash       metainfo.Hash
+	Name           string
+	Paused         bool
+	HaveInfo       bool
+	Length         int64 `json:",omitempty"`
+	BytesCompleted int64 `json:",omitempty"`
+	ActivePeers    int
+	TotalPeers     int
+	Files          []FileStatus `json:",omitempty"`
+}
+
+type FileStatus struct {
+	Path     string
+	Length   int64
+	Priority string
+}
+
+type StatsReply struct {
+	BytesWritten        int64
+	BytesWrittenData    int64
+	BytesRead           int64
+	BytesReadData       int64
+	BytesReadUsefulData int64
+	PiecesDirtiedGood   int64
+	PiecesDirtiedBad    int64
+	ActivePeers         int
+	TotalPeers          int
+}
+
+type Empty struct{}
+
+func (s *Service) Add(args AddArgs, reply *metainfo.Hash) (err error) {
+	var mi *metainfo.MetaInfo
+	switch {
+	case args.Metainfo != nil:
+		mi, err = metainfo.Load(bytes.NewReader(args.Metainfo))
+		if err != nil {
+			return err
+		}
+	case args.Magnet == "":
+		return errors.New("one of Magnet or Metainfo is required")
+	}
+	*reply, err = s.d.add(args.Magnet, mi, false)
+	return
+}
+
+func (s *Service) Remove(args InfoHashArgs, _ *Empty) error {
+	return s.d.remove(args.InfoHash)
+}
+
+func (s *Service) Pause(args InfoHashArgs, _ *Empty) error {
+	return s.d.setPaused(args.InfoHash, true)
+}
+
+func (s *Service) Resume(args InfoHashArgs, _ *Empty) error {
+	return s.d.setPaused(args.InfoHash, false)
+}
+
+func (s *Service) SetFilePriority(args SetFilePriorityArgs, _ *Empty) error {
+	return s.d.setFilePriority(args.InfoHash, args.File, args.Priority)
+}
+
+func (s *Service) List(_ Empty, reply *[]TorrentStatus) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	*reply = []TorrentStatus{}
+	for hex, st := range s.d.session.Torrents {
+		var ih metainfo.Hash
+		if err := ih.FromHexString(hex); err != nil {
+			continue
+		}
+		ts := TorrentStatus{
+			InfoHash: ih,
+			Paused:   st.Paused,
+		}
+		if t, ok := s.d.cl.Torrent(ih); ok {
+			ts.fill(t)
+		}
+		*reply = append(*reply, ts)
+	}
+	sort.Slice(*reply, func(i, j int) bool {
+		return (*reply)[i].InfoHash.HexString() < (*reply)[j].InfoHash.HexString()
+	})
+	return nil
+}
+
+func (ts *TorrentStatus) fill(t *torrent.Torrent) {
+	ts.Name = t.Name()
+	stats := t.Stats()
+	ts.ActivePeers = stats.ActivePeers
+	ts.TotalPeers = stats.TotalPeers
+	if t.Info() == nil {
+		return
+	}
+	ts.HaveInfo = true
+	ts.Length = t.Length()
+	ts.BytesCompleted = t.BytesCompleted()
+	for _, f := range t.Files() {
+		ts.Files = append(ts.Files, FileStatus{
+			Path:     f.DisplayPath(),
+			Length:   f.Length(),
+			Priority: priorityName(f),
+		})
+	}
+}
+
+// Returns stats for the torrent, or summed over all torrents if the infohash is zero.
+func (s *Service) Stats(args InfoHashArgs, reply *StatsReply) error {
+	var ts []*torrent.Torrent
+	if args.InfoHash == (metainfo.Hash{}) {
+		ts = s.d.cl.Torrents()
+	} else {
+		t, ok := s.d.cl.Torrent(args.InfoHash)
+		if !ok {
+			return errUnknownTorrent
+		}
+		ts = append(ts, t)
+	}
+	for _, t := range ts {
+		stats := t.Stats()
+		reply.BytesWritten += stats.BytesWritten.Int64()
+		reply.BytesWrittenData += stats.BytesWrittenData.Int64()
+		reply.BytesRead += stats.BytesRead.Int64()
+		reply.BytesReadData += stats.BytesReadData.Int64()
+		reply.BytesReadUsefulData += stats.BytesReadUsefulData.Int64()
+		reply.PiecesDirtiedGood += stats.PiecesDirtiedGood.Int64()
+		reply.PiecesDirtiedBad += stats.PiecesDirtiedBad.Int64()
+		reply.ActivePeers += stats.ActivePeers
+		reply.TotalPeers += stats.TotalPeers
+	}
+	return nil
+}
+
+// Adapts an HTTP request and response to the connection expected by the JSON-RPC codec.
+type httpConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (httpConn) Close() error {
+	return nil
+}
+
+const sessionIdHeader = "X-Torrentd-Session-Id"
+
+// Serves one JSON-RPC request per POST. Requests must be JSON and carry the session ID, which is
+// returned with a 409 response to requests without it, as in Transmission's RPC. Web pages can't
+// read that response, so they can't make requests to a daemon on localhost.
+func rpcHandler(server *rpc.Server) http.Handler {
+	var b [16]byte
+	rand.Read(b[:])
+	sessionId := hex.EncodeToString(b[:])
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get(sessionIdHeader) != sessionId {
+			w.Header().Set(sessionIdHeader, sessionId)
+			http.Error(w, fmt.Sprintf("%s: %s", sessionIdHeader, sessionId), http.StatusConflict)
+			return
+		}
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+			http.Error(w, "expected Content-Type application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := server.ServeRequest(jsonrpc.NewServerCodec(httpConn{r.Body, w})); err != nil {
+			log.Printf("error serving rpc request: %s", err)
+		}
+	})
+}
+
+func serveUnix(server *rpc.Server, l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Printf("stopped accepting rpc connections: %s", err)
+			return
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
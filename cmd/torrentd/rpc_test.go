@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/internal/testutil"
+)
+
+func TestRpcHandler(t *testing.T) {
+	d, err := newDaemon(newTestClient(t), t.TempDir())
+	require.NoError(t, err)
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("Torrentd", &Service{d}))
+	srv := httptest.NewServer(rpcHandler(server))
+	defer srv.Close()
+	mi := testutil.GreetingMetaInfo()
+	body := `{"method":"Torrentd.Pause","params":[{"InfoHash":"` + mi.HashInfoBytes().HexString() + `"}],"id":1}`
+	post := func(contentType, sessionId string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set(sessionIdHeader, sessionId)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	_, err = d.add("", mi, false)
+	require.NoError(t, err)
+	resp := post("application/json", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	sessionId := resp.Header.Get(sessionIdHeader)
+	require.NotEmpty(t, sessionId)
+	// What a web page can send without a preflight request.
+	resp = post("text/plain", sessionId)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	assert.False(t, d.session.Torrents[mi.HashInfoBytes().HexString()].Paused)
+
+	resp = post("application/json; charset=utf-8", sessionId)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var reply struct {
+		Error interface{}
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&reply))
+	assert.Nil(t, reply.Error)
+	assert.True(t, d.session.Torrents[mi.HashInfoBytes().HexString()].Paused)
+}
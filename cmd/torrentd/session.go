@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// The state that's persisted across restarts. Metainfo for torrents is stored separately, once the
+// info is known, so it needn't be fetched from peers again.
+type session struct {
+	Torrents map[string]*sessionTorrent `json:"torrents"`
+}
+
+type sessionTorrent struct {
+	// Used to add the torrent until its metainfo has been saved.
+	Magnet string `json:"magnet,omitempty"`
+	Paused bool   `json:"paused,omitempty"`
+	// File priorities by index that differ from the default of "normal".
+	FilePriorities map[int]string `json:"filePriorities,omitempty"`
+	// Set if the torrent was added from the watched directory, so it's removed with the file.
+	Watched bool `json:"watched,omitempty"`
+}
+
+const sessionFileName = "session.json"
+
+func loadSession(stateDir string) (s session, err error) {
+	s.Torrents = make(map[string]*sessionTorrent)
+	b, err := os.ReadFile(filepath.Join(stateDir, sessionFileName))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &s)
+	if s.Torrents == nil {
+		s.Torrents = make(map[string]*sessionTorrent)
+	}
+	return
+}
+
+// Writes the session to a temporary file first, so a crash can't leave it truncated.
+func (s *session) save(stateDir string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(stateDir, sessionFileName+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(stateDir, sessionFileName))
+}
+
+func metainfoPath(stateDir string, ih metainfo.Hash) string {
+	return filepath.Join(stateDir, ih.HexString()+".torrent")
+}
+
+func saveMetainfo(stateDir string, mi metainfo.MetaInfo) error {
+	ih := mi.HashInfoBytes()
+	tmp := metainfoPath(stateDir, ih) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = mi.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, metainfoPath(stateDir, ih))
+}
+
+// Returns a function that sets the named priority on a file. The priority type isn't exported by
+// package torrent, so it can't be passed around directly.
+func parsePriority(s string) (func(*torrent.File), error) {
+	switch s {
+	case "none":
+		return func(f *torrent.File) { f.SetPriority(torrent.PiecePriorityNone) }, nil
+	case "normal", "":
+		return func(f *torrent.File) { f.SetPriority(torrent.PiecePriorityNormal) }, nil
+	case "high":
+		return func(f *torrent.File) { f.SetPriority(torrent.PiecePriorityHigh) }, nil
+	}
+	return nil, fmt.Errorf("unknown priority %q, expected none, normal or high", s)
+}
+
+func priorityName(f *torrent.File) string {
+	switch prio := f.Priority(); {
+	case prio == torrent.PiecePriorityNone:
+		return "none"
+	case prio <= torrent.PiecePriorityNormal:
+		return "normal"
+	default:
+		return "high"
+	}
+}
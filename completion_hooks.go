@@ -0,0 +1,280 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Describes a torrent or file that finished downloading, for CompletionHooks.
+type Completion struct {
+	// The Torrent, or File within it, that completed. File is nil for whole torrents.
+	Torrent *Torrent `json:"-"`
+	File    *File    `json:"-"`
+
+	InfoHash string `json:"infoHash"`
+	// The torrent name.
+	Name string `json:"name"`
+	// The File.DisplayPath, or empty for whole torrents.
+	DisplayPath string `json:"displayPath,omitempty"`
+	// Where file storage keeps the data, relative to DataDir. See File.SafePath.
+	Path    string `json:"path"`
+	DataDir string `json:"dataDir"`
+	Length  int64  `json:"length"`
+}
+
+func (me Completion) IsFile() bool {
+	return me.File != nil
+}
+
+// Run when a File or Torrent finishes downloading. Errors cause the hook to be retried. See
+// ClientConfig.CompletionHooks.
+type CompletionHook interface {
+	OnCompletion(context.Context, Completion) error
+}
+
+type CompletionHookFunc func(context.Context, Completion) error
+
+func (f CompletionHookFunc) OnCompletion(ctx context.Context, c Completion) error {
+	return f(ctx, c)
+}
+
+// Runs an external command. Each argument is a text/template executed with the Completion, for
+// example "{{.DataDir}}/{{.Path}}". The Completion is also passed as JSON on stdin. The command's
+// output is included in the error if it fails.
+type CommandCompletionHook struct {
+	Path string
+	Args []string
+	// Whether to run for file completions, torrent completions, or both if neither is set.
+	Files, Torrents bool
+}
+
+func (me CommandCompletionHook) OnCompletion(ctx context.Context, c Completion) error {
+	if !wantsCompletion(me.Files, me.Torrents, c) {
+		return nil
+	}
+	args := make([]string, 0, len(me.Args))
+	for _, a := range me.Args {
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(a)
+		if err != nil {
+			return fmt.Errorf("parsing argument template %q: %w", a, err)
+		}
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, c); err != nil {
+			return fmt.Errorf("executing argument template %q: %w", a, err)
+		}
+		args = append(args, buf.String())
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, me.Path, args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running %q: %w: %q", me.Path, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// POSTs the Completion as JSON to URL. Any response status other than 2xx is an error.
+type WebhookCompletionHook struct {
+	URL    string
+	Header http.Header
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Whether to run for file completions, torrent completions, or both if neither is set.
+	Files, Torrents bool
+}
+
+func (me WebhookCompletionHook) OnCompletion(ctx context.Context, c Completion) error {
+	if !wantsCompletion(me.Files, me.Torrents, c) {
+		return nil
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, me.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, vs := range me.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := me.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %q: %s", me.URL, resp.Status)
+	}
+	return nil
+}
+
+func wantsCompletion(files, torrents bool, c Completion) bool {
+	if !files && !torrents {
+		return true
+	}
+	if c.IsFile() {
+		return files
+	}
+	return torrents
+}
+
+type completionHookJob struct {
+	hook CompletionHook
+	c    Completion
+}
+
+// Runs completion hooks on a bounded pool of workers, so slow hooks don't hold up the Client.
+type completionHookRunner struct {
+	hooks      []CompletionHook
+	attempts   int
+	retryDelay time.Duration
+	logger     func(format string, args ...interface{})
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	cond   sync.Cond
+	queue  []completionHookJob
+	closed bool
+}
+
+func newCompletionHookRunner(cfg *ClientConfig, logger func(string, ...interface{})) *completionHookRunner {
+	me := &completionHookRunner{
+		hooks:      cfg.CompletionHooks,
+		attempts:   cfg.CompletionHookAttempts,
+		retryDelay: cfg.CompletionHookRetryDelay,
+		logger:     logger,
+	}
+	if me.attempts < 1 {
+		me.attempts = 1
+	}
+	me.cond.L = &me.mu
+	me.ctx, me.cancel = context.WithCancel(context.Background())
+	workers := cfg.CompletionHookWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	me.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go me.worker()
+	}
+	return me
+}
+
+// Queues every hook for the Completion. Doesn't block.
+func (me *completionHookRunner) enqueue(c Completion) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.closed {
+		return
+	}
+	for _, h := range me.hooks {
+		me.queue = append(me.queue, completionHookJob{h, c})
+	}
+	me.cond.Broadcast()
+}
+
+func (me *completionHookRunner) worker() {
+	defer me.wg.Done()
+	for {
+		me.mu.Lock()
+		for len(me.queue) == 0 && !me.closed {
+			me.cond.Wait()
+		}
+		if me.closed {
+			me.mu.Unlock()
+			return
+		}
+		job := me.queue[0]
+		me.queue = me.queue[1:]
+		me.mu.Unlock()
+		me.run(job)
+	}
+}
+
+// Runs the job, retrying with exponential backoff until it succeeds, the attempts are exhausted,
+// or the runner is closed.
+func (me *completionHookRunner) run(job completionHookJob) {
+	delay := me.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := job.hook.OnCompletion(me.ctx, job.c)
+		if err == nil {
+			return
+		}
+		if attempt >= me.attempts || me.ctx.Err() != nil {
+			me.logger("completion hook %T for %q failed after %d attempts: %s", job.hook, job.c.Path, attempt, err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-me.ctx.Done():
+		}
+		delay *= 2
+	}
+}
+
+// Abandons queued jobs, cancels running hooks, and waits for the workers to return.
+func (me *completionHookRunner) close() {
+	me.mu.Lock()
+	me.closed = true
+	me.queue = nil
+	me.cond.Broadcast()
+	me.mu.Unlock()
+	me.cancel()
+	me.wg.Wait()
+}
+
+// Queues completion hooks for the files, and the torrent if all is set. See
+// Torrent.completedByPiece.
+func (t *Torrent) queueCompletionHooks(files []*File, all bool) {
+	runner := t.cl.completionHooks
+	if runner == nil {
+		return
+	}
+	dataDir := t.cl.config.DataDir
+	for _, f := range files {
+		runner.enqueue(Completion{
+			Torrent:     t,
+			File:        f,
+			InfoHash:    t.infoHash.HexString(),
+			Name:        t.info.BestName(),
+			DisplayPath: f.DisplayPath(),
+			Path:        f.SafePath(),
+			DataDir:     dataDir,
+			Length:      f.Length(),
+		})
+	}
+	if all {
+		runner.enqueue(Completion{
+			Torrent:  t,
+			InfoHash: t.infoHash.HexString(),
+			Name:     t.info.BestName(),
+			Path:     t.info.SanitizedName(),
+			DataDir:  dataDir,
+			Length:   *t.length,
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package torrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/internal/testutil"
+)
+
+func TestCompletionHookRunnerRetries(t *testing.T) {
+	var attempts int32
+	calls := make(chan Completion, 3)
+	hook := CompletionHookFunc(func(_ context.Context, c Completion) error {
+		calls <- c
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	r := newCompletionHookRunner(&ClientConfig{
+		CompletionHooks:        []CompletionHook{hook},
+		CompletionHookAttempts: 3,
+	}, t.Logf)
+	defer r.close()
+	r.enqueue(Completion{Path: "a"})
+	for i := 0; i < 2; i++ {
+		select {
+		case c := <-calls:
+			assert.Equal(t, "a", c.Path)
+		case <-time.After(time.Second):
+			t.Fatal("hook wasn't retried")
+		}
+	}
+	assert.Never(t, func() bool { return atomic.LoadInt32(&attempts) > 2 }, 100*time.Millisecond, time.Millisecond)
+}
+
+func TestWebhookCompletionHook(t *testing.T) {
+	var got Completion
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	hook := WebhookCompletionHook{URL: srv.URL, Torrents: true}
+	c := Completion{InfoHash: "ab", Name: "n", Path: "n", Length: 3}
+	require.NoError(t, hook.OnCompletion(context.Background(), c))
+	assert.Equal(t, c, got)
+	got = Completion{}
+	c.File = &File{}
+	require.NoError(t, hook.OnCompletion(context.Background(), c))
+	assert.Equal(t, Completion{}, got)
+}
+
+func TestCommandCompletionHookOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	hook := CommandCompletionHook{Path: "sh", Args: []string{"-c", "echo {{.Path}} failed; exit 1"}}
+	err := hook.OnCompletion(context.Background(), Completion{Path: "a"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a failed")
+	hook.Args = []string{"-c", "echo {{.Path}}"}
+	assert.NoError(t, hook.OnCompletion(context.Background(), Completion{Path: "a"}))
+}
+
+func TestCompletionHooksAndEventsForDownload(t *testing.T) {
+	completions := make(chan Completion, 2)
+	_, leecher := testSeederLeecher(t, func(cfg *ClientConfig) {
+		cfg.CompletionHooks = []CompletionHook{CompletionHookFunc(func(_ context.Context, c Completion) error {
+			completions <- c
+			return nil
+		})}
+		cfg.CompletionHookWorkers = 1
+	})
+	events := leecher.cl.SubscribeEvents(2, EventFileCompleted, EventTorrentCompleted)
+	defer events.Close()
+	leecher.DownloadAll()
+	for _, expected := range []EventType{EventFileCompleted, EventTorrentCompleted} {
+		select {
+		case e := <-events.Events():
+			assert.Equal(t, expected, e.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v event", expected)
+		}
+	}
+	for _, file := range []bool{true, false} {
+		select {
+		case c := <-completions:
+			assert.Equal(t, file, c.IsFile())
+			assert.Equal(t, testutil.GreetingFileName, c.Path)
+		case <-time.After(5 * time.Second):
+			t.Fatal("hook wasn't run")
+		}
+	}
+}
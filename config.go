@@ -13,6 +13,7 @@ import (
 	"github.com/anacrolix/missinggo/conntrack"
 	"github.com/anacrolix/missinggo/expect"
 	"github.com/anacrolix/torrent/iplist"
+	"github.com/anacrolix/torrent/peerid"
 	"github.com/anacrolix/torrent/storage"
 	"golang.org/x/time/rate"
 )
@@ -79,6 +80,8 @@ type ClientConfig struct {
 	// 			 http://proxy.domain.com:3128
 	ProxyURL string
 
+	// Peers and DHT nodes in these ranges are ignored. See iplist.LoadFiles, and
+	// Client.SetIPBlocklist to change it later.
 	IPBlocklist      iplist.Ranger
 	DisableIPv6      bool `long:"disable-ipv6"`
 	DisableIPv4      bool
@@ -130,11 +133,24 @@ type ClientConfig struct {
 	// Don't add connections that have the same peer ID as an existing
 	// connection for a given Torrent.
 	dropDuplicatePeerIds bool
+	// Close connections to peers whose peer IDs identify clients matching any of these, as soon as
+	// the handshake completes.
+	RejectPeerClients []peerid.Pattern
 
 	ConnTracker *conntrack.Instance
 
 	// OnQuery hook func
 	DHTOnQuery func(query *krpc.Msg, source net.Addr) (propagate bool)
+
+	// Run for each File, and then each Torrent, completed by data downloaded by the Client. Data
+	// found already present when a torrent is added doesn't trigger hooks.
+	CompletionHooks []CompletionHook
+	// The number of hooks that may run concurrently.
+	CompletionHookWorkers int
+	// How many times a failing hook is run before giving up.
+	CompletionHookAttempts int
+	// The delay before the first retry of a failed hook. It doubles for each retry.
+	CompletionHookRetryDelay time.Duration
 }
 
 func (cfg *ClientConfig) SetListenAddr(addr string) *ClientConfig {
@@ -167,6 +183,9 @@ func NewDefaultClientConfig() *ClientConfig {
 		UploadRateLimiter:                 unlimited,
 		DownloadRateLimiter:               unlimited,
 		ConnTracker:                       conntrack.NewInstance(),
+		CompletionHookWorkers:             4,
+		CompletionHookAttempts:            3,
+		CompletionHookRetryDelay:          time.Second,
 	}
 	cc.ConnTracker.SetNoMaxEntries()
 	cc.ConnTracker.Timeout = func(conntrack.Entry) time.Duration { return 0 }
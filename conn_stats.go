@@ -66,8 +66,9 @@ func (me *Count) String() string {
 	return fmt.Sprintf("%v", me.Int64())
 }
 
-func (me *Count) MarshalJSON() ([]byte, error) {
-	return json.Marshal(me.n)
+// A value receiver, so that Counts in snapshots like ClientStatus encode without being addressable.
+func (me Count) MarshalJSON() ([]byte, error) {
+	return json.Marshal(atomic.LoadInt64(&me.n))
 }
 
 func (cs *ConnStats) wroteMsg(msg *pp.Message) {
@@ -24,20 +24,23 @@ import (
 	"github.com/pkg/errors"
 )
 
-type peerSource string
+// How a peer came to be known. See Peer.Source and PeerConnInfo.Discovery.
+type PeerSource string
 
 const (
-	peerSourceTracker         = "Tr"
-	peerSourceIncoming        = "I"
-	peerSourceDHTGetPeers     = "Hg" // Peers we found by searching a DHT.
-	peerSourceDHTAnnouncePeer = "Ha" // Peers that were announced to us by a DHT.
-	peerSourcePEX             = "X"
+	PeerSourceTracker         PeerSource = "Tr"
+	PeerSourceIncoming        PeerSource = "I"
+	PeerSourceDHTGetPeers     PeerSource = "Hg" // Peers we found by searching a DHT.
+	PeerSourceDHTAnnouncePeer PeerSource = "Ha" // Peers that were announced to us by a DHT.
+	PeerSourcePEX             PeerSource = "X"
+	PeerSourceDirect          PeerSource = "D" // Peers given with the torrent, such as magnet x.pe.
 )
 
 // Maintains the state of a connection with a peer.
 type connection struct {
 	// First to ensure 64-bit alignment for atomics. See #262.
 	stats ConnStats
+	rates transferRates
 
 	t *Torrent
 	// The actual Conn, used for closing, and setting socket options.
@@ -52,7 +55,7 @@ type connection struct {
 	// True if the connection is operating over MSE obfuscation.
 	headerEncrypted bool
 	cryptoMethod    mse.CryptoMethod
-	Discovery       peerSource
+	Discovery       PeerSource
 	closed          missinggo.Event
 	// Set true after we've added our ConnStats generated during handshake to
 	// other ConnStat instances as determined when the *Torrent became known.
@@ -197,14 +200,6 @@ func (cn *connection) bestPeerNumPieces() pieceIndex {
 	return cn.peerMinPieces
 }
 
-func (cn *connection) completedString() string {
-	have := pieceIndex(cn.peerPieces.Len())
-	if cn.peerSentHaveAll {
-		have = cn.bestPeerNumPieces()
-	}
-	return fmt.Sprintf("%d/%d", have, cn.bestPeerNumPieces())
-}
-
 // Correct the PeerPieces slice length. Return false if the existing slice is
 // invalid, such as by receiving badly sized BITFIELD, or invalid HAVE
 // messages.
@@ -270,43 +265,9 @@ func (cn *connection) statusFlags() (ret string) {
 // 	return buf.String()
 // }
 
-func (cn *connection) downloadRate() float64 {
-	return float64(cn.stats.BytesReadUsefulData.Int64()) / cn.cumInterest().Seconds()
-}
-
 func (cn *connection) WriteStatus(w io.Writer, t *Torrent) {
-	// \t isn't preserved in <pre> blocks?
-	fmt.Fprintf(w, "%+-55q %s %s-%s\n", cn.PeerID, cn.PeerExtensionBytes, cn.localAddr(), cn.remoteAddr)
-	fmt.Fprintf(w, "    last msg: %s, connected: %s, last helpful: %s, itime: %s, etime: %s\n",
-		eventAgeString(cn.lastMessageReceived),
-		eventAgeString(cn.completedHandshake),
-		eventAgeString(cn.lastHelpful()),
-		cn.cumInterest(),
-		cn.totalExpectingTime(),
-	)
-	fmt.Fprintf(w,
-		"    %s completed, %d pieces touched, good chunks: %v/%v-%v reqq: (%d,%d,%d]-%d, flags: %s, dr: %.1f KiB/s\n",
-		cn.completedString(),
-		len(cn.peerTouchedPieces),
-		&cn.stats.ChunksReadUseful,
-		&cn.stats.ChunksRead,
-		&cn.stats.ChunksWritten,
-		cn.requestsLowWater,
-		cn.numLocalRequests(),
-		cn.nominalMaxRequests(),
-		len(cn.PeerRequests),
-		cn.statusFlags(),
-		cn.downloadRate()/(1<<10),
-	)
-	fmt.Fprintf(w, "    next pieces: %v%s\n",
-		iter.ToSlice(iter.Head(10, cn.iterPendingPiecesUntyped)),
-		func() string {
-			if cn.shouldRequestWithoutBias() {
-				return " (fastest)"
-			} else {
-				return ""
-			}
-		}())
+	s := cn.status()
+	s.Write(w)
 }
 
 func (cn *connection) Close() {
@@ -327,7 +288,7 @@ func (cn *connection) PeerHasPiece(piece pieceIndex) bool {
 
 // Writes a message into the write buffer.
 func (cn *connection) Post(msg pp.Message) {
-	torrent.Add(fmt.Sprintf("messages posted of type %s", msg.Type.String()), 1)
+	cn.t.cl.metrics.torrent.Add(fmt.Sprintf("messages posted of type %s", msg.Type.String()), 1)
 	// We don't need to track bytes here because a connection.w Writer wrapper
 	// takes care of that (although there's some delay between us recording
 	// the message, and the connection writer flushing it out.).
@@ -410,7 +371,7 @@ func (cn *connection) totalExpectingTime() (ret time.Duration) {
 
 func (cn *connection) onPeerSentCancel(r request) {
 	if _, ok := cn.PeerRequests[r]; !ok {
-		torrent.Add("unexpected cancels received", 1)
+		cn.t.cl.metrics.torrent.Add("unexpected cancels received", 1)
 		return
 	}
 	if cn.fastEnabled() {
@@ -492,7 +453,7 @@ func (cn *connection) request(r request, mw messageWriter) bool {
 	}
 	if cn.PeerChoked {
 		if cn.peerAllowedFast.Get(int(r.Index)) {
-			torrent.Add("allowed fast requests sent", 1)
+			cn.t.cl.metrics.torrent.Add("allowed fast requests sent", 1)
 		} else {
 			panic("requesting while choked and not allowed fast")
 		}
@@ -513,7 +474,7 @@ func (cn *connection) request(r request, mw messageWriter) bool {
 	cn.validReceiveChunks[r] = struct{}{}
 	cn.t.pendingRequests[r]++
 	cn.t.lastRequested[r] = time.AfterFunc(cn.t.duplicateRequestTimeout, func() {
-		torrent.Add("duplicate request timeouts", 1)
+		cn.t.cl.metrics.torrent.Add("duplicate request timeouts", 1)
 		cn.mu().Lock()
 		defer cn.mu().Unlock()
 		delete(cn.t.lastRequested, r)
@@ -614,13 +575,13 @@ func (cn *connection) writer(keepAliveTimeout time.Duration) {
 			cn.fillWriteBuffer(func(msg pp.Message) bool {
 				cn.wroteMsg(&msg)
 				cn.writeBuffer.Write(msg.MustMarshalBinary())
-				torrent.Add(fmt.Sprintf("messages filled of type %s", msg.Type.String()), 1)
+				cn.t.cl.metrics.torrent.Add(fmt.Sprintf("messages filled of type %s", msg.Type.String()), 1)
 				return cn.writeBuffer.Len() < 1<<16 // 64KiB
 			})
 		}
 		if cn.writeBuffer.Len() == 0 && time.Since(lastWrite) >= keepAliveTimeout {
 			cn.writeBuffer.Write(pp.Message{Keepalive: true}.MustMarshalBinary())
-			postedKeepalives.Add(1)
+			cn.t.cl.metrics.postedKeepalives.Add(1)
 		}
 		if cn.writeBuffer.Len() == 0 {
 			// TODO: Minimize wakeups....
@@ -947,8 +908,12 @@ func (c *connection) requestPendingMetadata() {
 }
 
 func (cn *connection) wroteMsg(msg *pp.Message) {
-	torrent.Add(fmt.Sprintf("messages written of type %s", msg.Type.String()), 1)
+	cn.t.cl.metrics.torrent.Add(fmt.Sprintf("messages written of type %s", msg.Type.String()), 1)
 	cn.allStats(func(cs *ConnStats) { cs.wroteMsg(msg) })
+	if msg.Type == pp.Piece {
+		now := time.Now()
+		cn.allRates(func(r *transferRates) { r.upload.add(now, int64(len(msg.Piece))) })
+	}
 }
 
 func (cn *connection) readMsg(msg *pp.Message) {
@@ -1021,25 +986,25 @@ func (c *connection) reject(r request) {
 }
 
 func (c *connection) onReadRequest(r request) error {
-	requestedChunkLengths.Add(strconv.FormatUint(r.Length.Uint64(), 10), 1)
+	c.t.cl.metrics.requestedChunkLengths.Add(strconv.FormatUint(r.Length.Uint64(), 10), 1)
 	if r.Begin+r.Length > c.t.pieceLength(pieceIndex(r.Index)) {
-		torrent.Add("bad requests received", 1)
+		c.t.cl.metrics.torrent.Add("bad requests received", 1)
 		return errors.New("bad request")
 	}
 	if _, ok := c.PeerRequests[r]; ok {
-		torrent.Add("duplicate requests received", 1)
+		c.t.cl.metrics.torrent.Add("duplicate requests received", 1)
 		return nil
 	}
 	if c.Choked {
-		torrent.Add("requests received while choking", 1)
+		c.t.cl.metrics.torrent.Add("requests received while choking", 1)
 		if c.fastEnabled() {
-			torrent.Add("requests rejected while choking", 1)
+			c.t.cl.metrics.torrent.Add("requests rejected while choking", 1)
 			c.reject(r)
 		}
 		return nil
 	}
 	if len(c.PeerRequests) >= maxRequests {
-		torrent.Add("requests received while queue full", 1)
+		c.t.cl.metrics.torrent.Add("requests received while queue full", 1)
 		if c.fastEnabled() {
 			c.reject(r)
 		}
@@ -1050,7 +1015,7 @@ func (c *connection) onReadRequest(r request) error {
 		// This isn't necessarily them screwing up. We can drop pieces
 		// from our storage, and can't communicate this to peers
 		// except by reconnecting.
-		requestsReceivedForMissingPieces.Add(1)
+		c.t.cl.metrics.requestsReceivedForMissingPieces.Add(1)
 		return fmt.Errorf("peer requested piece we don't have: %v", r.Index.Int())
 	}
 	if c.PeerRequests == nil {
@@ -1066,9 +1031,9 @@ func (c *connection) onReadRequest(r request) error {
 func (c *connection) mainReadLoop() (err error) {
 	defer func() {
 		if err != nil {
-			torrent.Add("connection.mainReadLoop returned with error", 1)
+			c.t.cl.metrics.torrent.Add("connection.mainReadLoop returned with error", 1)
 		} else {
-			torrent.Add("connection.mainReadLoop returned with no error", 1)
+			c.t.cl.metrics.torrent.Add("connection.mainReadLoop returned with no error", 1)
 		}
 	}()
 	t := c.t
@@ -1095,10 +1060,10 @@ func (c *connection) mainReadLoop() (err error) {
 		c.readMsg(&msg)
 		c.lastMessageReceived = time.Now()
 		if msg.Keepalive {
-			receivedKeepalives.Add(1)
+			c.t.cl.metrics.receivedKeepalives.Add(1)
 			continue
 		}
-		messageTypesReceived.Add(msg.Type.String(), 1)
+		c.t.cl.metrics.messageTypesReceived.Add(msg.Type.String(), 1)
 		if msg.Type.FastExtension() && !c.fastEnabled() {
 			return fmt.Errorf("received fast extension message (type=%v) but extension is disabled", msg.Type)
 		}
@@ -1160,12 +1125,12 @@ func (c *connection) mainReadLoop() (err error) {
 				go s.Ping(&pingAddr)
 			})
 		case pp.AllowedFast:
-			torrent.Add("allowed fasts received", 1)
+			c.t.cl.metrics.torrent.Add("allowed fasts received", 1)
 			// log.Fmsg("peer allowed fast: %d", msg.Index).AddValues(c, debugLogValue).Log(c.t.logger)
 			c.peerAllowedFast.Add(int(msg.Index))
 			c.updateRequests()
 		case pp.Suggest:
-			torrent.Add("suggests received", 1)
+			c.t.cl.metrics.torrent.Add("suggests received", 1)
 			// log.Fmsg("peer suggested piece %d", msg.Index).AddValues(c, msg.Index, debugLogValue).Log(c.t.logger)
 			c.updateRequests()
 		default:
@@ -1206,7 +1171,7 @@ func (c *connection) onReadExtendedMsg(id pp.ExtensionNumber, payload []byte) (e
 		}
 		for name, id := range d.M {
 			if _, ok := c.PeerExtensionIDs[name]; !ok {
-				torrent.Add(fmt.Sprintf("peers supporting extension %q", name), 1)
+				c.t.cl.metrics.torrent.Add(fmt.Sprintf("peers supporting extension %q", name), 1)
 			}
 			c.PeerExtensionIDs[name] = id
 		}
@@ -1236,7 +1201,7 @@ func (c *connection) onReadExtendedMsg(id pp.ExtensionNumber, payload []byte) (e
 		if err != nil {
 			return fmt.Errorf("error unmarshalling PEX message: %s", err)
 		}
-		torrent.Add("pex added6 peers received", int64(len(pexMsg.Added6)))
+		c.t.cl.metrics.torrent.Add("pex added6 peers received", int64(len(pexMsg.Added6)))
 		var peers Peers
 		peers.AppendFromPex(pexMsg.Added6, pexMsg.Added6Flags)
 		peers.AppendFromPex(pexMsg.Added, pexMsg.AddedFlags)
@@ -1265,22 +1230,22 @@ func (cn *connection) rw() io.ReadWriter {
 func (c *connection) receiveChunk(msg *pp.Message) error {
 	t := c.t
 	cl := t.cl
-	torrent.Add("chunks received", 1)
+	c.t.cl.metrics.torrent.Add("chunks received", 1)
 
 	req := newRequestFromMessage(msg)
 
 	if c.PeerChoked {
-		torrent.Add("chunks received while choked", 1)
+		c.t.cl.metrics.torrent.Add("chunks received while choked", 1)
 	}
 
 	if _, ok := c.validReceiveChunks[req]; !ok {
-		torrent.Add("chunks received unexpected", 1)
+		c.t.cl.metrics.torrent.Add("chunks received unexpected", 1)
 		return errors.New("received unexpected chunk")
 	}
 	delete(c.validReceiveChunks, req)
 
 	if c.PeerChoked && c.peerAllowedFast.Get(int(req.Index)) {
-		torrent.Add("chunks received due to allowed fast", 1)
+		c.t.cl.metrics.torrent.Add("chunks received due to allowed fast", 1)
 	}
 
 	// Request has been satisfied.
@@ -1289,12 +1254,12 @@ func (c *connection) receiveChunk(msg *pp.Message) error {
 			c.chunksReceivedWhileExpecting++
 		}
 	} else {
-		torrent.Add("chunks received unwanted", 1)
+		c.t.cl.metrics.torrent.Add("chunks received unwanted", 1)
 	}
 
 	// Do we actually want this chunk?
 	if t.haveChunk(req) {
-		torrent.Add("chunks received wasted", 1)
+		c.t.cl.metrics.torrent.Add("chunks received wasted", 1)
 		c.allStats(add(1, func(cs *ConnStats) *Count { return &cs.ChunksReadWasted }))
 		return nil
 	}
@@ -1304,6 +1269,7 @@ func (c *connection) receiveChunk(msg *pp.Message) error {
 	c.allStats(add(1, func(cs *ConnStats) *Count { return &cs.ChunksReadUseful }))
 	c.allStats(add(int64(len(msg.Piece)), func(cs *ConnStats) *Count { return &cs.BytesReadUsefulData }))
 	c.lastUsefulChunkReceived = time.Now()
+	c.allRates(func(r *transferRates) { r.download.add(c.lastUsefulChunkReceived, int64(len(msg.Piece))) })
 	// if t.fastestConn != c {
 	// log.Printf("setting fastest connection %p", c)
 	// }
@@ -1336,10 +1302,11 @@ func (c *connection) receiveChunk(msg *pp.Message) error {
 	piece.decrementPendingWrites()
 
 	if err != nil {
-		panic(fmt.Sprintf("error writing chunk: %v", err))
-		// t.pendRequest(req)
-		// t.updatePieceCompletion(pieceIndex(msg.Index))
-		// return nil
+		t.logger.Printf("error writing chunk %v: %s", req, err)
+		t.publishEvent(Event{Type: EventStorageError, Piece: int(msg.Index), Err: err})
+		t.pendRequest(req)
+		t.updatePieceCompletion(pieceIndex(msg.Index))
+		return nil
 	}
 
 	// It's important that the piece is potentially queued before we check if
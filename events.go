@@ -0,0 +1,204 @@
+package torrent
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventTorrentAdded EventType = iota
+	EventTorrentRemoved
+	// The torrent info became available.
+	EventMetadataReceived
+	// A piece passed its hash check. This includes the initial check of existing data.
+	EventPieceVerified
+	// A piece failed its hash check.
+	EventPieceFailed
+	// All pieces containing data for the File are complete.
+	EventFileCompleted
+	// All pieces of the torrent are complete.
+	EventTorrentCompleted
+	EventPeerConnected
+	EventPeerDisconnected
+	// An announce to a tracker finished, successfully or not.
+	EventTrackerAnnounce
+	// Reading from or writing to storage failed.
+	EventStorageError
+)
+
+func (me EventType) String() string {
+	switch me {
+	case EventTorrentAdded:
+		return "torrent added"
+	case EventTorrentRemoved:
+		return "torrent removed"
+	case EventMetadataReceived:
+		return "metadata received"
+	case EventPieceVerified:
+		return "piece verified"
+	case EventPieceFailed:
+		return "piece failed"
+	case EventFileCompleted:
+		return "file completed"
+	case EventTorrentCompleted:
+		return "torrent completed"
+	case EventPeerConnected:
+		return "peer connected"
+	case EventPeerDisconnected:
+		return "peer disconnected"
+	case EventTrackerAnnounce:
+		return "tracker announce"
+	case EventStorageError:
+		return "storage error"
+	default:
+		return "unknown event"
+	}
+}
+
+// Something that happened in a Client. Fields not relevant to the Type are zero.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Torrent *Torrent
+	// For piece events, and storage errors involving a piece.
+	Piece int
+	// For EventFileCompleted.
+	File *File
+	// For peer events.
+	PeerAddr IpPort
+	PeerID   PeerID
+	// For EventTrackerAnnounce.
+	Tracker  string
+	NumPeers int
+	// Set for failed announces and storage errors.
+	Err error
+}
+
+// Receives Client events. See Client.SubscribeEvents.
+type EventSubscription struct {
+	bus     *eventBus
+	c       chan Event
+	types   map[EventType]bool
+	dropped int64
+}
+
+// Events are delivered on this channel, which is closed when the subscription is closed.
+func (me *EventSubscription) Events() <-chan Event {
+	return me.c
+}
+
+// The number of events discarded because the subscriber's buffer was full.
+func (me *EventSubscription) Dropped() int64 {
+	return atomic.LoadInt64(&me.dropped)
+}
+
+// Stops delivery and closes the Events channel. Safe to call more than once.
+func (me *EventSubscription) Close() {
+	me.bus.unsubscribe(me)
+}
+
+func (me *EventSubscription) wants(t EventType) bool {
+	return len(me.types) == 0 || me.types[t]
+}
+
+// Fans events out to subscribers without blocking. Has its own lock so that subscriptions can be
+// managed without the Client lock, which is usually held when publishing.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*EventSubscription]struct{}
+}
+
+func (me *eventBus) subscribe(buffer int, types []EventType) *EventSubscription {
+	s := &EventSubscription{
+		bus: me,
+		c:   make(chan Event, buffer),
+	}
+	if len(types) != 0 {
+		s.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.subs == nil {
+		me.subs = make(map[*EventSubscription]struct{})
+	}
+	me.subs[s] = struct{}{}
+	return s
+}
+
+func (me *eventBus) unsubscribe(s *EventSubscription) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if _, ok := me.subs[s]; !ok {
+		return
+	}
+	delete(me.subs, s)
+	close(s.c)
+}
+
+func (me *eventBus) publish(e Event) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if len(me.subs) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	for s := range me.subs {
+		if !s.wants(e.Type) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// Returns a subscription to events of the given types, or all events if none are given. Up to
+// buffer events are queued for the subscriber; further events are dropped until it catches up, so
+// a slow subscriber never stalls the Client. Close the subscription when done with it.
+func (cl *Client) SubscribeEvents(buffer int, types ...EventType) *EventSubscription {
+	return cl.events.subscribe(buffer, types)
+}
+
+func (t *Torrent) publishEvent(e Event) {
+	e.Torrent = t
+	t.cl.events.publish(e)
+}
+
+func (t *Torrent) publishConnEvent(typ EventType, c *connection) {
+	t.publishEvent(Event{
+		Type:     typ,
+		PeerAddr: c.remoteAddr,
+		PeerID:   c.PeerID,
+	})
+}
+
+// Publishes completion events for the files, and the torrent if all is set. See
+// Torrent.completedByPiece.
+func (t *Torrent) publishCompletionEvents(files []*File, all bool) {
+	for _, f := range files {
+		t.publishEvent(Event{Type: EventFileCompleted, File: f})
+	}
+	if all {
+		t.publishEvent(Event{Type: EventTorrentCompleted})
+	}
+}
+
+func (t *Torrent) fileComplete(f *File) bool {
+	if f.length == 0 {
+		return true
+	}
+	for i := f.firstPieceIndex(); i < f.endPieceIndex(); i++ {
+		if !t.pieceComplete(i) {
+			return false
+		}
+	}
+	return true
+}
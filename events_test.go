@@ -0,0 +1,33 @@
+package torrent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBusFilteringAndDropping(t *testing.T) {
+	var bus eventBus
+	all := bus.subscribe(1, nil)
+	pieces := bus.subscribe(4, []EventType{EventPieceVerified, EventPieceFailed})
+	bus.publish(Event{Type: EventTorrentAdded})
+	bus.publish(Event{Type: EventPieceVerified, Piece: 1})
+	bus.publish(Event{Type: EventPieceFailed, Piece: 2})
+
+	e := <-all.Events()
+	assert.Equal(t, EventTorrentAdded, e.Type)
+	assert.False(t, e.Time.IsZero())
+	assert.EqualValues(t, 2, all.Dropped())
+
+	assert.Len(t, pieces.Events(), 2)
+	assert.EqualValues(t, 1, (<-pieces.Events()).Piece)
+	assert.EqualValues(t, 2, (<-pieces.Events()).Piece)
+	assert.EqualValues(t, 0, pieces.Dropped())
+
+	pieces.Close()
+	pieces.Close()
+	_, ok := <-pieces.Events()
+	assert.False(t, ok)
+	bus.publish(Event{Type: EventPieceVerified})
+	all.Close()
+}
@@ -1,6 +1,9 @@
 package torrent
 
 import (
+	"path"
+	"strings"
+
 	"github.com/anacrolix/torrent/metainfo"
 )
 
@@ -13,6 +16,7 @@ type File struct {
 	fi          metainfo.FileInfo
 	displayPath string
 	prio        piecePriority
+	safePath    string
 }
 
 func (f *File) Torrent() *Torrent {
@@ -34,6 +38,13 @@ func (f File) Path() string {
 	return f.path
 }
 
+// Like Path, but with each component sanitized so it's safe to use on common filesystems, and
+// distinct from the SafePath of every other file in the torrent. Storage and anything else exposing
+// file names should use this. See metainfo.Info.SanitizedFilePaths.
+func (f *File) SafePath() string {
+	return f.safePath
+}
+
 // The file's length in bytes.
 func (f *File) Length() int64 {
 	return f.length
@@ -110,6 +121,7 @@ func (f *File) SetPriority(prio piecePriority) {
 		return
 	}
 	f.prio = prio
+	f.t.updateSequentialWindow()
 	f.t.updatePiecePriorities(f.firstPieceIndex(), f.endPieceIndex())
 }
 
@@ -120,6 +132,48 @@ func (f *File) Priority() piecePriority {
 	return f.prio
 }
 
+// Raises the pieces containing the first headBytes and last tailBytes of the file to high priority.
+// Media containers often need data from both ends, such as an MP4 moov atom or MKV cues, before
+// playback can start.
+func (f *File) PrioritizeEdges(headBytes, tailBytes int64) {
+	f.t.cl.lock()
+	defer f.t.cl.unlock()
+	f.prioritizeEdges(headBytes, tailBytes)
+}
+
+func (f *File) prioritizeEdges(headBytes, tailBytes int64) {
+	headBytes = min(headBytes, f.length)
+	tailBytes = min(tailBytes, f.length)
+	if headBytes > 0 {
+		begin, end := f.t.byteRegionPieces(f.offset, headBytes)
+		f.t.raisePiecesPriority(begin, end, PiecePriorityHigh)
+	}
+	if tailBytes > 0 {
+		begin, end := f.t.byteRegionPieces(f.offset+f.length-tailBytes, tailBytes)
+		f.t.raisePiecesPriority(begin, end, PiecePriorityHigh)
+	}
+}
+
+// Selects files by name extension whose edges should be prioritized. See File.PrioritizeEdges.
+type FileEdgePriorities struct {
+	// Extensions including the leading '.', matched case-insensitively.
+	Extensions           []string
+	HeadBytes, TailBytes int64
+}
+
+// Common media container extensions for use with FileEdgePriorities.
+var DefaultMediaExtensions = []string{".mp4", ".m4v", ".mov", ".mkv", ".webm", ".avi"}
+
+func (fep FileEdgePriorities) matches(f *File) bool {
+	ext := path.Ext(f.DisplayPath())
+	for _, e := range fep.Extensions {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *File) firstPieceIndex() pieceIndex {
 	if f.t.usualPieceSize() == 0 {
 		return 0
@@ -0,0 +1,69 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Returns a reader of the file that creates its Reader on the first Read. Seeking, such as to find
+// the size or the offset of a range, and opening the file only for its properties, don't
+// prioritize any data. configure is called with the Reader when it's created, and may be nil.
+// Reads are abandoned when ctx is done.
+func (f *File) NewReadSeeker(ctx context.Context, configure func(Reader)) io.ReadSeekCloser {
+	return &fileReadSeeker{
+		f:         f,
+		ctx:       ctx,
+		configure: configure,
+	}
+}
+
+type fileReadSeeker struct {
+	f         *File
+	ctx       context.Context
+	configure func(Reader)
+	pos       int64
+	reader    Reader
+}
+
+func (me *fileReadSeeker) Read(b []byte) (int, error) {
+	if me.reader == nil {
+		me.reader = me.f.NewReader()
+		if me.configure != nil {
+			me.configure(me.reader)
+		}
+		if _, err := me.reader.Seek(me.pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	n, err := me.reader.ReadContext(me.ctx, b)
+	me.pos += int64(n)
+	return n, err
+}
+
+func (me *fileReadSeeker) Seek(off int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		off += me.pos
+	case io.SeekEnd:
+		off += me.f.Length()
+	default:
+		return me.pos, errors.New("bad whence")
+	}
+	if off < 0 {
+		return me.pos, errors.New("negative position")
+	}
+	me.pos = off
+	if me.reader != nil {
+		return me.reader.Seek(off, io.SeekStart)
+	}
+	return off, nil
+}
+
+func (me *fileReadSeeker) Close() error {
+	if me.reader == nil {
+		return nil
+	}
+	return me.reader.Close()
+}
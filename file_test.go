@@ -20,3 +20,18 @@ func TestFileExclusivePieces(t *testing.T) {
 		assert.EqualValues(t, _case.end, end)
 	}
 }
+
+func TestFilePrioritizeEdges(t *testing.T) {
+	tor := addTenPieceTorrent(t)
+	tor.Piece(1).SetPriority(PiecePriorityReadahead)
+	f := tor.Files()[0]
+	// The head covers pieces 0 and 1, and the tail piece 9.
+	f.PrioritizeEdges(6, 3)
+	for i, prio := range []piecePriority{
+		PiecePriorityHigh, PiecePriorityReadahead, PiecePriorityNone, PiecePriorityNone,
+		PiecePriorityNone, PiecePriorityNone, PiecePriorityNone, PiecePriorityNone,
+		PiecePriorityNone, PiecePriorityHigh,
+	} {
+		assert.Equal(t, prio, tor.PieceState(i).Priority, i)
+	}
+}
@@ -1,32 +1,44 @@
 package iplist
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
 	"io"
 	"net"
 )
 
-func ParseCIDRListReader(r io.Reader) (ret []Range, err error) {
-	s := bufio.NewScanner(r)
-	for s.Scan() {
-		err = func() (err error) {
-			_, in, err := net.ParseCIDR(s.Text())
-			if err != nil {
-				return
-			}
-			ret = append(ret, Range{
-				First: in.IP,
-				Last:  IPNetLast(in),
-			})
-			return
-		}()
-		if err != nil {
+// Parses a line containing a CIDR block such as "10.0.0.0/8", or a single IP. Returns !ok but no
+// error for comments and blank lines.
+func ParseCIDRListLine(l []byte) (r Range, ok bool, err error) {
+	l = bytes.TrimSpace(l)
+	if len(l) == 0 || l[0] == '#' {
+		return
+	}
+	if bytes.IndexByte(l, '/') == -1 {
+		r.First = parseIP(string(l))
+		if r.First == nil {
+			err = errors.New("bad IP")
 			return
 		}
+		r.Last = r.First
+		ok = true
+		return
+	}
+	_, in, err := net.ParseCIDR(string(l))
+	if err != nil {
+		return
 	}
+	r.First = in.IP
+	r.Last = IPNetLast(in)
+	ok = true
 	return
 }
 
+// Parses a line-delimited list of CIDR blocks and IPs.
+func ParseCIDRListReader(r io.Reader) (ret []Range, err error) {
+	return parseLines(r, ParseCIDRListLine)
+}
+
 // Returns the last, inclusive IP in a net.IPNet.
 func IPNetLast(in *net.IPNet) (last net.IP) {
 	n := len(in.IP)
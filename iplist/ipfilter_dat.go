@@ -0,0 +1,104 @@
+package iplist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Ranges in eMule ipfilter.dat files with an access level at or above this are permitted, and
+// aren't included in the blocklist.
+const ipFilterDatPermitLevel = 128
+
+// Parses a line of the eMule ipfilter.dat format, such as "001.002.004.000 - 001.002.004.255 ,
+// 000 , Some description". Returns !ok but no error for comments, blank lines, and ranges with a
+// permitting access level.
+func ParseIPFilterDatLine(l []byte) (r Range, ok bool, err error) {
+	l = bytes.TrimSpace(l)
+	if len(l) == 0 || l[0] == '#' || bytes.HasPrefix(l, []byte("//")) {
+		return
+	}
+	fields := bytes.SplitN(l, []byte(","), 3)
+	ips := bytes.SplitN(fields[0], []byte("-"), 2)
+	if len(ips) != 2 {
+		err = errors.New("missing hyphen")
+		return
+	}
+	r.First = parseIP(string(bytes.TrimSpace(ips[0])))
+	r.Last = parseIP(string(bytes.TrimSpace(ips[1])))
+	if r.First == nil || r.Last == nil || len(r.First) != len(r.Last) {
+		err = errors.New("bad IP range")
+		return
+	}
+	if len(fields) >= 2 {
+		var level int
+		level, err = strconv.Atoi(string(bytes.TrimSpace(fields[1])))
+		if err != nil {
+			err = fmt.Errorf("bad access level: %w", err)
+			return
+		}
+		if level >= ipFilterDatPermitLevel {
+			return
+		}
+	}
+	if len(fields) == 3 {
+		r.Description = string(bytes.TrimSpace(fields[2]))
+	}
+	ok = true
+	return
+}
+
+// Parses the ranges blocked by an eMule ipfilter.dat file.
+func ParseIPFilterDatReader(r io.Reader) (ret []Range, err error) {
+	return parseLines(r, ParseIPFilterDatLine)
+}
+
+func parseLines(r io.Reader, parseLine func([]byte) (Range, bool, error)) (ret []Range, err error) {
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		r, ok, lineErr := parseLine(s.Bytes())
+		if lineErr != nil {
+			err = fmt.Errorf("error parsing line %d: %s", lineNum, lineErr)
+			return
+		}
+		if ok {
+			ret = append(ret, r)
+		}
+	}
+	err = s.Err()
+	return
+}
+
+// Parses an IP, allowing IPv4 octets with leading zeroes, which are common in blocklists but
+// rejected by net.ParseIP. IPv4 addresses are returned in their 4 byte form.
+func parseIP(s string) (ip net.IP) {
+	ip = net.ParseIP(s)
+	if ip == nil {
+		ip = parseZeroPaddedIPv4(s)
+	}
+	minifyIP(&ip)
+	return
+}
+
+func parseZeroPaddedIPv4(s string) net.IP {
+	octets := bytes.Split([]byte(s), []byte("."))
+	if len(octets) != 4 {
+		return nil
+	}
+	var ret [4]byte
+	for i, o := range octets {
+		if len(o) == 0 || len(o) > 3 {
+			return nil
+		}
+		n, err := strconv.ParseUint(string(o), 10, 8)
+		if err != nil {
+			return nil
+		}
+		ret[i] = byte(n)
+	}
+	return net.IPv4(ret[0], ret[1], ret[2], ret[3])
+}
@@ -35,7 +35,7 @@ func (r Range) String() string {
 
 // Create a new IP list. The given ranges must already sorted by the lower
 // bound IP in each range. Behaviour is undefined for lists of overlapping
-// ranges.
+// ranges. See Merge.
 func New(initSorted []Range) *IPList {
 	return &IPList{
 		ranges: initSorted,
@@ -92,16 +92,27 @@ func lookup(
 		if i+1 >= n {
 			return true
 		}
-		return bytes.Compare(ip, first(i+1)) < 0
+		return compareIP(ip, first(i+1)) < 0
 	})
 	if i == n {
 		return
 	}
 	r = full(i)
-	ok = bytes.Compare(r.First, ip) <= 0 && bytes.Compare(ip, r.Last) <= 0
+	ok = compareIP(r.First, ip) <= 0 && compareIP(ip, r.Last) <= 0
 	return
 }
 
+// Orders IPs of different lengths by length, so that lists can contain both 4 and 16 byte ranges.
+func compareIP(a, b net.IP) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(a, b)
+}
+
 // Return the range the given IP is in. Returns nil if no range is found.
 func (ipl *IPList) lookup(ip net.IP) (Range, bool) {
 	return lookup(func(i int) net.IP {
@@ -139,10 +150,8 @@ func ParseBlocklistP2PLine(l []byte) (r Range, ok bool, err error) {
 	}
 	hyphen += colon + 1
 	r.Description = string(l[:colon])
-	r.First = net.ParseIP(string(l[colon+1 : hyphen]))
-	minifyIP(&r.First)
-	r.Last = net.ParseIP(string(l[hyphen+1:]))
-	minifyIP(&r.Last)
+	r.First = parseIP(string(l[colon+1 : hyphen]))
+	r.Last = parseIP(string(l[hyphen+1:]))
 	if r.First == nil || r.Last == nil || len(r.First) != len(r.Last) {
 		err = errors.New("bad IP range")
 		return
@@ -0,0 +1,151 @@
+package iplist
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+)
+
+type Format int
+
+const (
+	// Detect the format from the first line that isn't blank or a comment.
+	FormatAuto Format = iota
+	// The PeerGuardian P2P plaintext format, see ParseBlocklistP2PLine.
+	FormatP2P
+	// The eMule ipfilter.dat format, see ParseIPFilterDatLine.
+	FormatIPFilterDat
+	// CIDR blocks or IPs, see ParseCIDRListLine.
+	FormatCIDR
+)
+
+func (f Format) lineParser() func([]byte) (Range, bool, error) {
+	switch f {
+	case FormatP2P:
+		return ParseBlocklistP2PLine
+	case FormatIPFilterDat:
+		return ParseIPFilterDatLine
+	case FormatCIDR:
+		return ParseCIDRListLine
+	}
+	return nil
+}
+
+// Returns the first format that successfully parses the first line containing a range. The
+// ipfilter.dat and P2P formats are tried last, as their descriptions can contain anything.
+func detectFormat(br *bufio.Reader) (Format, error) {
+	// Look ahead without consuming, so the parser sees the whole input.
+	b, err := br.Peek(64 << 10)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return FormatAuto, err
+	}
+	for len(b) != 0 {
+		var l []byte
+		if i := bytes.IndexByte(b, '\n'); i == -1 {
+			l, b = b, nil
+		} else {
+			l, b = b[:i], b[i+1:]
+		}
+		l = bytes.TrimSpace(l)
+		if len(l) == 0 || l[0] == '#' || bytes.HasPrefix(l, []byte("//")) {
+			continue
+		}
+		for _, f := range []Format{FormatCIDR, FormatIPFilterDat, FormatP2P} {
+			if _, _, err := f.lineParser()(l); err == nil {
+				return f, nil
+			}
+		}
+		return FormatAuto, fmt.Errorf("unrecognized blocklist line %q", l)
+	}
+	// Nothing to parse, so any format will do.
+	return FormatCIDR, nil
+}
+
+// Parses the ranges in r, which may be gzipped.
+func ParseReader(r io.Reader, f Format) (ret []Range, err error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		br = bufio.NewReaderSize(gr, 64<<10)
+	}
+	if f == FormatAuto {
+		f, err = detectFormat(br)
+		if err != nil {
+			return
+		}
+	}
+	parseLine := f.lineParser()
+	if parseLine == nil {
+		return nil, fmt.Errorf("unknown format %d", f)
+	}
+	return parseLines(br, parseLine)
+}
+
+// Loads and merges the ranges from each of the files, detecting their formats, and whether they're
+// gzipped.
+func LoadFiles(paths ...string) (*IPList, error) {
+	var all []Range
+	for _, p := range paths {
+		rs, err := func() ([]Range, error) {
+			f, err := os.Open(p)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return ParseReader(f, FormatAuto)
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("loading %q: %w", p, err)
+		}
+		all = append(all, rs...)
+	}
+	return New(Merge(all)), nil
+}
+
+// Sorts the ranges and merges those that overlap or are adjacent, as required by New. Merged ranges
+// keep the description of the range that starts first. IPv4 ranges should be in their 4 byte form.
+// The given slice is reused.
+func Merge(rs []Range) []Range {
+	sort.Slice(rs, func(i, j int) bool {
+		return compareIP(rs[i].First, rs[j].First) < 0
+	})
+	ret := rs[:0]
+	for _, r := range rs {
+		if compareIP(r.First, r.Last) > 0 {
+			// Empty.
+			continue
+		}
+		if n := len(ret); n != 0 {
+			last := &ret[n-1]
+			if len(last.Last) == len(r.First) && compareIP(r.First, nextIP(last.Last)) <= 0 {
+				if compareIP(r.Last, last.Last) > 0 {
+					last.Last = r.Last
+				}
+				continue
+			}
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// Returns the IP following ip, or ip if it's the last of its length.
+func nextIP(ip net.IP) net.IP {
+	ret := append(net.IP(nil), ip...)
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i]++
+		if ret[i] != 0 {
+			return ret
+		}
+	}
+	return ip
+}
@@ -0,0 +1,51 @@
+package iplist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReaderFormats(t *testing.T) {
+	for _, s := range []string{
+		"# comment\nsomething:1.2.3.0-1.2.3.255\n",
+		"// comment\n001.002.003.000 - 001.002.003.255 , 000 , something\n010.0.0.0 - 010.0.0.255 , 200 , permitted\n",
+		"\n1.2.3.0/24\n",
+	} {
+		rs, err := ParseReader(strings.NewReader(s), FormatAuto)
+		require.NoError(t, err, s)
+		require.Len(t, rs, 1, s)
+		assert.EqualValues(t, net.IPv4(1, 2, 3, 0).To4(), rs[0].First)
+		assert.EqualValues(t, net.IPv4(1, 2, 3, 255).To4(), rs[0].Last)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("1.2.3.4\n"))
+	w.Close()
+	rs, err := ParseReader(&buf, FormatAuto)
+	require.NoError(t, err)
+	assert.Len(t, rs, 1)
+}
+
+func TestMerge(t *testing.T) {
+	rs, err := ParseCIDRListReader(strings.NewReader("1.2.4.0/24\n1.2.3.0/24\n1.2.3.128/25\n1.2.6.0/24\n::1\n"))
+	require.NoError(t, err)
+	ipl := New(Merge(rs))
+	assert.Equal(t, 3, ipl.NumRanges())
+	for ip, blocked := range map[string]bool{
+		"1.2.3.4":   true,
+		"1.2.4.255": true,
+		"1.2.5.0":   false,
+		"1.2.6.1":   true,
+		"::1":       true,
+		"::2":       false,
+	} {
+		_, ok := ipl.Lookup(net.ParseIP(ip))
+		assert.Equal(t, blocked, ok, ip)
+	}
+}
@@ -0,0 +1,180 @@
+package torrent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/anacrolix/log"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func (t *Torrent) addWebseeds(urls []string) {
+	t.metainfo.UrlList = appendMissingStrings(t.metainfo.UrlList, urls)
+}
+
+// Returns the BEP 19 web seed URLs known for the torrent.
+func (t *Torrent) Webseeds() []string {
+	t.cl.rLock()
+	defer t.cl.rUnlock()
+	return append([]string(nil), t.metainfo.UrlList...)
+}
+
+// Selects files for download by their index in the info, per BEP 53. Applied when the info arrives
+// if it isn't available yet.
+func (t *Torrent) selectFiles(ranges []metainfo.FileIndexRange) {
+	t.selectedFiles = append(t.selectedFiles, ranges...)
+	if t.haveInfo() {
+		t.applySelectedFiles()
+	}
+}
+
+func (t *Torrent) applySelectedFiles() {
+	if len(t.selectedFiles) == 0 {
+		return
+	}
+	ranges := t.selectedFiles
+	t.selectedFiles = nil
+	selected := func(i int) bool {
+		for _, r := range ranges {
+			if r.Contains(i) {
+				return true
+			}
+		}
+		return false
+	}
+	// Indexes include padding files, which aren't in t.files.
+	files := *t.files
+	for i, fi := range t.info.UpvertedFiles() {
+		if fi.IsPad() {
+			continue
+		}
+		f := files[0]
+		files = files[1:]
+		if selected(i) && f.prio < PiecePriorityNormal {
+			f.prio = PiecePriorityNormal
+			t.updatePiecePriorities(f.firstPieceIndex(), f.endPieceIndex())
+		}
+	}
+	t.updateSequentialWindow()
+}
+
+// Adds peers given as host:port. IP addresses are added immediately, and host names are resolved
+// in the background.
+func (t *Torrent) addPeerAddrs(addrs []string) {
+	for _, addr := range addrs {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			t.logger.Printf("bad peer address %q: %s", addr, err)
+			continue
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			t.logger.Printf("bad peer address %q: %s", addr, err)
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			t.addPeer(Peer{IP: ip, Port: int(port), Source: PeerSourceDirect})
+			continue
+		}
+		go t.resolvePeerAddr(host, int(port))
+	}
+}
+
+func (t *Torrent) resolvePeerAddr(host string, port int) {
+	ctx, cancel := t.closedContext()
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		t.logger.WithDefaultLevel(log.Debug).Printf("error resolving peer host %q: %s", host, err)
+		return
+	}
+	t.cl.lock()
+	defer t.cl.unlock()
+	for _, ip := range ips {
+		t.addPeer(Peer{IP: ip, Port: port, Source: PeerSourceDirect})
+	}
+}
+
+// Returns a context that's cancelled when the torrent is closed.
+func (t *Torrent) closedContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-t.Closed():
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// Metainfo from sources larger than this is rejected.
+const maxSourceMetainfoSize = 64 << 20
+
+// Fetches the metainfo from each source over HTTP until the info is obtained by any means.
+func (t *Torrent) useSources(sources []string) {
+	for _, s := range sources {
+		go t.useSource(s)
+	}
+}
+
+func (t *Torrent) useSource(url string) {
+	select {
+	case <-t.GotInfo():
+		return
+	default:
+	}
+	ctx, cancel := t.closedContext()
+	defer cancel()
+	go func() {
+		select {
+		case <-t.GotInfo():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	mi, err := t.fetchSource(ctx, url)
+	if err != nil {
+		if ctx.Err() == nil {
+			t.logger.Printf("error fetching metainfo from source %q: %s", url, err)
+		}
+		return
+	}
+	t.cl.lock()
+	defer t.cl.unlock()
+	if t.haveInfo() || t.closed.IsSet() {
+		return
+	}
+	if err := t.setInfoBytes(mi.InfoBytes); err != nil {
+		t.logger.Printf("error setting info from source %q: %s", url, err)
+	}
+}
+
+func (t *Torrent) fetchSource(ctx context.Context, url string) (*metainfo.MetaInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", t.cl.config.HTTPUserAgent)
+	resp, err := t.cl.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+	mi, err := metainfo.Load(io.LimitReader(resp.Body, maxSourceMetainfoSize))
+	if err != nil {
+		return nil, fmt.Errorf("error loading metainfo: %w", err)
+	}
+	if mi.HashInfoBytes() != t.infoHash {
+		return nil, fmt.Errorf("metainfo has infohash %v", mi.HashInfoBytes())
+	}
+	return mi, nil
+}
@@ -0,0 +1,93 @@
+package torrent
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/internal/testutil"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestSelectFilesBeforeInfo(t *testing.T) {
+	cl := newTestingClient(t, testingConfig(t))
+	infoBytes, err := bencode.Marshal(metainfo.Info{
+		Name:        "dir",
+		PieceLength: 5,
+		Pieces:      make([]byte, 3*20),
+		Files: []metainfo.FileInfo{
+			{Path: []string{"a"}, Length: 5},
+			{Path: []string{"b"}, Length: 5},
+			{Path: []string{"c"}, Length: 5},
+		},
+	})
+	require.NoError(t, err)
+	tor, _ := cl.AddTorrentInfoHash(metainfo.HashBytes(infoBytes))
+	cl.lock()
+	// The second range runs past the last file.
+	tor.selectFiles([]metainfo.FileIndexRange{{Begin: 1, End: 1}, {Begin: 2, End: math.MaxInt}})
+	cl.unlock()
+	require.NoError(t, tor.SetInfoBytes(infoBytes))
+	files := tor.Files()
+	assert.Equal(t, PiecePriorityNone, files[0].Priority())
+	assert.Equal(t, PiecePriorityNormal, files[1].Priority())
+	assert.Equal(t, PiecePriorityNormal, files[2].Priority())
+}
+
+// The info is fetched from an exact source, and the peer is added by host name.
+func TestMagnetPeerAddrsAndSources(t *testing.T) {
+	seeder := testGreetingSeeder(t)
+	mi := seeder.Metainfo()
+	other := testutil.Torrent{Name: "other", Files: []testutil.File{{Data: "other"}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/greeting.torrent":
+			mi.Write(w)
+		case "/other.torrent":
+			other.Metainfo(5).Write(w)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	m := metainfo.Magnet{
+		InfoHash: seeder.InfoHash(),
+		PeerAddrs: []string{
+			"bad",
+			fmt.Sprintf("localhost:%d", seeder.cl.LocalPort()),
+		},
+		ExactSources: []string{
+			srv.URL + "/missing",
+			srv.URL + "/other.torrent",
+			srv.URL + "/greeting.torrent",
+		},
+	}
+	spec, err := TorrentSpecFromMagnetURI(m.String())
+	require.NoError(t, err)
+	leecher, _, err := newTestingClient(t, testingConfig(t)).AddTorrentSpec(spec)
+	require.NoError(t, err)
+	select {
+	case <-leecher.GotInfo():
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't get info")
+	}
+	requireNumPeerConns(t, leecher, 1)
+	assert.Equal(t, PeerSourceDirect, leecher.PeerConns()[0].Discovery)
+}
+
+func TestMagnetWebSeedsParams(t *testing.T) {
+	m := metainfo.MetaInfo{UrlList: []string{"http://ws"}}
+	magnet := m.Magnet(&metainfo.Hash{}, nil)
+	assert.Equal(t, []string{"http://ws"}, magnet.WebSeeds)
+	u, err := url.Parse(magnet.String())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://ws"}, u.Query()["ws"])
+}
@@ -7,6 +7,40 @@ type FileInfo struct {
 	Length   int64    `bencode:"length"` // BEP3
 	Path     []string `bencode:"path"`   // BEP3
 	PathUtf8 []string `bencode:"path.utf-8,omitempty"`
+	// Characters from FileAttr*.
+	Attr        string   `bencode:"attr,omitempty"`         // BEP47
+	Sha1        string   `bencode:"sha1,omitempty"`         // BEP47
+	SymlinkPath []string `bencode:"symlink path,omitempty"` // BEP47
+}
+
+// File attributes from BEP 47.
+const (
+	FileAttrPad        = 'p'
+	FileAttrExecutable = 'x'
+	FileAttrHidden     = 'h'
+	FileAttrSymlink    = 'l'
+)
+
+func (fi *FileInfo) hasAttr(attr rune) bool {
+	return strings.ContainsRune(fi.Attr, attr)
+}
+
+// A padding file only exists to align the next file to a piece boundary. Its contents are zeros,
+// and it shouldn't be presented to users, requested from peers, or written to storage.
+func (fi *FileInfo) IsPad() bool {
+	return fi.hasAttr(FileAttrPad)
+}
+
+func (fi *FileInfo) IsExecutable() bool {
+	return fi.hasAttr(FileAttrExecutable)
+}
+
+func (fi *FileInfo) IsHidden() bool {
+	return fi.hasAttr(FileAttrHidden)
+}
+
+func (fi *FileInfo) IsSymlink() bool {
+	return fi.hasAttr(FileAttrSymlink)
 }
 
 func (fi *FileInfo) DisplayPath(info *Info) string {
@@ -1,11 +1,13 @@
 package metainfo
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/anacrolix/missinggo/v2/slices"
@@ -36,6 +38,49 @@ const NoName = "-"
 
 // This is a helper that sets Files and Pieces from a root path and its children.
 func (info *Info) BuildFromFilePath(root string) (err error) {
+	err = info.setFilesFromFilePath(root)
+	if err != nil {
+		return
+	}
+	err = info.GeneratePieces(func(fi FileInfo) (io.ReadCloser, error) {
+		return os.Open(filePathJoin(root, fi))
+	})
+	if err != nil {
+		err = fmt.Errorf("error generating pieces: %s", err)
+	}
+	return
+}
+
+type BuildFromFilePathOpts struct {
+	// Add padding files so that each file begins at a piece boundary. See AlignFilesToPieces.
+	PieceAligned bool
+	GeneratePiecesOpts
+}
+
+// Like BuildFromFilePath, but hashes pieces concurrently. See GeneratePiecesParallel.
+func (info *Info) BuildFromFilePathParallel(ctx context.Context, root string, opts BuildFromFilePathOpts) (err error) {
+	err = info.setFilesFromFilePath(root)
+	if err != nil {
+		return
+	}
+	if opts.PieceAligned {
+		info.AlignFilesToPieces()
+	}
+	err = info.GeneratePiecesParallel(ctx, func(fi FileInfo) (FileReaderAt, error) {
+		return os.Open(filePathJoin(root, fi))
+	}, opts.GeneratePiecesOpts)
+	if err != nil {
+		err = fmt.Errorf("error generating pieces: %w", err)
+	}
+	return
+}
+
+func filePathJoin(root string, fi FileInfo) string {
+	return filepath.Join(root, strings.Join(fi.Path, string(filepath.Separator)))
+}
+
+// Sets Name, and Length or Files, and chooses a PieceLength if one isn't set.
+func (info *Info) setFilesFromFilePath(root string) (err error) {
 	info.Name = func() string {
 		b := filepath.Base(root)
 		switch b {
@@ -77,19 +122,52 @@ func (info *Info) BuildFromFilePath(root string) (err error) {
 	if info.PieceLength == 0 {
 		info.PieceLength = ChoosePieceLength(info.TotalLength())
 	}
-	err = info.GeneratePieces(func(fi FileInfo) (io.ReadCloser, error) {
-		return os.Open(filepath.Join(root, strings.Join(fi.Path, string(filepath.Separator))))
-	})
-	if err != nil {
-		err = fmt.Errorf("error generating pieces: %s", err)
-	}
 	return
 }
 
+// Replaces any padding files with BEP 47 padding files after each file that doesn't end on a piece
+// boundary, so that every file begins at the start of a piece. Files can then be verified and
+// shared independently. PieceLength must be set, and Pieces must be generated afterwards.
+func (info *Info) AlignFilesToPieces() {
+	if !info.IsDir() || info.PieceLength <= 0 {
+		return
+	}
+	var files []FileInfo
+	for _, fi := range info.Files {
+		if !fi.IsPad() {
+			files = append(files, fi)
+		}
+	}
+	info.Files = nil
+	var offset int64
+	for i, fi := range files {
+		info.Files = append(info.Files, fi)
+		offset += fi.Length
+		if i == len(files)-1 {
+			break
+		}
+		if rem := offset % info.PieceLength; rem != 0 {
+			pad := info.PieceLength - rem
+			info.Files = append(info.Files, FileInfo{
+				Length: pad,
+				Path:   []string{".pad", strconv.FormatInt(pad, 10)},
+				Attr:   string(FileAttrPad),
+			})
+			offset += pad
+		}
+	}
+}
+
 // Concatenates all the files in the torrent into w. open is a function that
 // gets at the contents of the given file.
 func (info *Info) writeFiles(w io.Writer, open func(fi FileInfo) (io.ReadCloser, error)) error {
 	for _, fi := range info.UpvertedFiles() {
+		if fi.IsPad() {
+			if _, err := io.CopyN(w, zeroReader{}, fi.Length); err != nil {
+				return err
+			}
+			continue
+		}
 		r, err := open(fi)
 		if err != nil {
 			return fmt.Errorf("error opening %v: %s", fi, err)
@@ -104,7 +182,7 @@ func (info *Info) writeFiles(w io.Writer, open func(fi FileInfo) (io.ReadCloser,
 }
 
 // Sets Pieces (the block of piece hashes in the Info) by using the passed
-// function to get at the torrent data.
+// function to get at the torrent data. Padding files are read as zeros without being opened.
 func (info *Info) GeneratePieces(open func(fi FileInfo) (io.ReadCloser, error)) (err error) {
 	if info.PieceLength == 0 {
 		return errors.New("piece length must be non-zero")
@@ -6,15 +6,27 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // Magnet link components.
 type Magnet struct {
-	InfoHash    Hash       // Expected in this implementation
-	Trackers    []string   // "tr" values
-	DisplayName string     // "dn" value, if not empty
-	Params      url.Values // All other values, such as "x.pe", "as", "xs" etc.
+	InfoHash    Hash     // Expected in this implementation
+	Trackers    []string // "tr" values
+	DisplayName string   // "dn" value, if not empty
+	// "so" value (BEP 53). Ranges of indexes into the info's files to download. Nil if not given.
+	SelectOnly []FileIndexRange
+	// "x.pe" values. Peer addresses in host:port form.
+	PeerAddrs []string
+	// "ws" values (BEP 19). Web seed URLs.
+	WebSeeds []string
+	// "xs" values. URLs where the metainfo for the torrent can be fetched.
+	ExactSources []string
+	// "as" values. URLs for the torrent data, or possibly its metainfo.
+	AcceptableSources []string
+	Params            url.Values // All other values
 }
 
 const xtPrefix = "urn:btih:"
@@ -32,6 +44,22 @@ func (m Magnet) String() string {
 	if m.DisplayName != "" {
 		vs.Add("dn", m.DisplayName)
 	}
+	if m.SelectOnly != nil {
+		vs.Set("so", formatSelectOnly(m.SelectOnly))
+	}
+	for _, f := range []struct {
+		key    string
+		values []string
+	}{
+		{"x.pe", m.PeerAddrs},
+		{"ws", m.WebSeeds},
+		{"xs", m.ExactSources},
+		{"as", m.AcceptableSources},
+	} {
+		for _, v := range f.values {
+			vs.Add(f.key, v)
+		}
+	}
 
 	// Transmission and Deluge both expect "urn:btih:" to be unescaped. Deluge wants it to be at the
 	// start of the magnet link. The InfoHash field is expected to be BitTorrent in this
@@ -72,6 +100,26 @@ func ParseMagnetUri(uri string) (m Magnet, err error) {
 	dropFirst(q, "dn")
 	m.Trackers = q["tr"]
 	delete(q, "tr")
+	if so, ok := q["so"]; ok {
+		m.SelectOnly, err = parseSelectOnly(strings.Join(so, ","))
+		if err != nil {
+			err = fmt.Errorf("error parsing so %q: %w", so, err)
+			return
+		}
+		delete(q, "so")
+	}
+	for _, f := range []struct {
+		key    string
+		values *[]string
+	}{
+		{"x.pe", &m.PeerAddrs},
+		{"ws", &m.WebSeeds},
+		{"xs", &m.ExactSources},
+		{"as", &m.AcceptableSources},
+	} {
+		*f.values = q[f.key]
+		delete(q, f.key)
+	}
 	if len(q) == 0 {
 		q = nil
 	}
@@ -118,3 +166,68 @@ func dropFirst(vs url.Values, key string) {
 		vs[key] = sl[1:]
 	}
 }
+
+// An inclusive range of file indexes, as in a BEP 53 file selection.
+type FileIndexRange struct {
+	Begin, End int
+}
+
+func (r FileIndexRange) Contains(index int) bool {
+	return index >= r.Begin && index <= r.End
+}
+
+// Parses a BEP 53 file selection, like "0,2,4-6". Ranges are kept as given, since they may be
+// larger than any real torrent.
+func parseSelectOnly(s string) (ret []FileIndexRange, err error) {
+	ret = []FileIndexRange{}
+	for _, r := range strings.Split(s, ",") {
+		if r == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(r, "-")
+		var begin, end int
+		begin, err = strconv.Atoi(first)
+		if err != nil {
+			return
+		}
+		end = begin
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil {
+				return
+			}
+		}
+		if begin < 0 || end < begin {
+			err = fmt.Errorf("bad range %q", r)
+			return
+		}
+		ret = append(ret, FileIndexRange{begin, end})
+	}
+	return
+}
+
+// Formats file index ranges for BEP 53, merging any that overlap or adjoin.
+func formatSelectOnly(ranges []FileIndexRange) string {
+	sorted := append([]FileIndexRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Begin < sorted[j].Begin
+	})
+	var parts []string
+	for i := 0; i < len(sorted); {
+		r := sorted[i]
+		i++
+		// Compared as End >= Begin-1 so that an End of math.MaxInt can't overflow.
+		for i < len(sorted) && r.End >= sorted[i].Begin-1 {
+			if sorted[i].End > r.End {
+				r.End = sorted[i].End
+			}
+			i++
+		}
+		if r.Begin == r.End {
+			parts = append(parts, strconv.Itoa(r.Begin))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.Begin, r.End))
+		}
+	}
+	return strings.Join(parts, ",")
+}
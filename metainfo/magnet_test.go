@@ -0,0 +1,42 @@
+package metainfo
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMagnetUriExtensions(t *testing.T) {
+	const uri = "magnet:?xt=urn:btih:51340689c960f0778a4387aef9b4b52fd08390cd" +
+		"&so=0,2,4-6&x.pe=1.2.3.4:6881&x.pe=peer.example:1&ws=http%3A%2F%2Fws&xs=http%3A%2F%2Fxs" +
+		"&as=http%3A%2F%2Fas&foo=bar"
+	m, err := ParseMagnetUri(uri)
+	require.NoError(t, err)
+	assert.Equal(t, []FileIndexRange{{0, 0}, {2, 2}, {4, 6}}, m.SelectOnly)
+	assert.Equal(t, []string{"1.2.3.4:6881", "peer.example:1"}, m.PeerAddrs)
+	assert.Equal(t, []string{"http://ws"}, m.WebSeeds)
+	assert.Equal(t, []string{"http://xs"}, m.ExactSources)
+	assert.Equal(t, []string{"http://as"}, m.AcceptableSources)
+	assert.Equal(t, []string{"bar"}, m.Params["foo"])
+	assert.Len(t, m.Params, 1)
+	m2, err := ParseMagnetUri(m.String())
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+	assert.Contains(t, m.String(), "so=0%2C2%2C4-6")
+	_, err = ParseMagnetUri("magnet:?xt=urn:btih:51340689c960f0778a4387aef9b4b52fd08390cd&so=3-1")
+	assert.Error(t, err)
+}
+
+func TestParseMagnetUriSelectOnlyLargeRanges(t *testing.T) {
+	const prefix = "magnet:?xt=urn:btih:51340689c960f0778a4387aef9b4b52fd08390cd&so="
+	maxInt := strconv.Itoa(math.MaxInt)
+	m, err := ParseMagnetUri(prefix + "5,0-1000000000,1000000001-" + maxInt)
+	require.NoError(t, err)
+	assert.Equal(t, []FileIndexRange{{5, 5}, {0, 1000000000}, {1000000001, math.MaxInt}}, m.SelectOnly)
+	assert.Contains(t, m.String(), "so=0-"+maxInt)
+	_, err = ParseMagnetUri(prefix + "0-" + maxInt + "0")
+	assert.Error(t, err)
+}
@@ -3,7 +3,6 @@ package metainfo
 import (
 	"bufio"
 	"io"
-	"net/url"
 	"os"
 	"time"
 
@@ -69,7 +68,8 @@ func (mi *MetaInfo) SetDefaults() {
 	mi.CreationDate = time.Now().Unix()
 }
 
-// Creates a Magnet from a MetaInfo. Optional infohash and parsed info can be provided.
+// Creates a Magnet from a MetaInfo. Optional infohash and parsed info can be provided. The UrlList
+// is put in WebSeeds, and not in Params["ws"].
 func (mi MetaInfo) Magnet(infoHash *Hash, info *Info) (m Magnet) {
 	m.Trackers = append(m.Trackers, mi.UpvertedAnnounceList().DistinctValues()...)
 	if info != nil {
@@ -80,8 +80,7 @@ func (mi MetaInfo) Magnet(infoHash *Hash, info *Info) (m Magnet) {
 	} else {
 		m.InfoHash = mi.HashInfoBytes()
 	}
-	m.Params = make(url.Values)
-	m.Params["ws"] = mi.UrlList
+	m.WebSeeds = append(m.WebSeeds, mi.UrlList...)
 	return
 }
 
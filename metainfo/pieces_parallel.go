@@ -0,0 +1,257 @@
+package metainfo
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// File data for GeneratePiecesParallel. ReadAt must be safe to call concurrently, as it is for
+// *os.File.
+type FileReaderAt interface {
+	io.ReaderAt
+	io.Closer
+}
+
+type GeneratePiecesOpts struct {
+	// The number of pieces hashed concurrently. Defaults to runtime.NumCPU().
+	Workers int
+	// Called as each piece is hashed with the total bytes hashed so far, and the total length of
+	// the torrent. Calls are serialized.
+	Progress func(hashed, total int64)
+}
+
+// Sets Pieces like GeneratePieces, but hashes pieces concurrently. The result is the same. Files
+// are opened when first needed, and closed after their last piece is hashed. Padding files are read
+// as zeros. Returns the context's error if it's cancelled.
+func (info *Info) GeneratePiecesParallel(
+	ctx context.Context,
+	open func(fi FileInfo) (FileReaderAt, error),
+	opts GeneratePiecesOpts,
+) (err error) {
+	if info.PieceLength == 0 {
+		return errors.New("piece length must be non-zero")
+	}
+	total := info.TotalLength()
+	numPieces := int((total + info.PieceLength - 1) / info.PieceLength)
+	files := newParallelHashFiles(info.UpvertedFiles(), info.PieceLength, open)
+	defer files.closeAll()
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > numPieces {
+		workers = numPieces
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		next     int
+		hashed   int64
+		firstErr error
+		pieces   = make([]byte, numPieces*sha1.Size)
+		wg       sync.WaitGroup
+	)
+	// Returns the next piece to hash, or false if there's nothing left to do.
+	take := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next >= numPieces || firstErr != nil {
+			return 0, false
+		}
+		next++
+		return next - 1, true
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 32<<10)
+			for {
+				piece, ok := take()
+				if !ok {
+					return
+				}
+				n, err := files.hashPiece(ctx, piece, total, pieces[piece*sha1.Size:piece*sha1.Size], buf)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+					return
+				}
+				hashed += n
+				if opts.Progress != nil {
+					opts.Progress(hashed, total)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if numPieces == 0 {
+		pieces = nil
+	}
+	info.Pieces = pieces
+	return nil
+}
+
+// Tracks the files of an Info while pieces are hashed concurrently.
+type parallelHashFiles struct {
+	pieceLength int64
+	open        func(FileInfo) (FileReaderAt, error)
+	files       []*parallelHashFile
+	// The offset of the end of each file in the torrent, for finding the files a piece overlaps.
+	ends []int64
+}
+
+type parallelHashFile struct {
+	fi     FileInfo
+	offset int64
+
+	mu sync.Mutex
+	r  FileReaderAt
+	// The number of pieces overlapping this file that haven't been hashed.
+	pending int
+}
+
+func newParallelHashFiles(fis []FileInfo, pieceLength int64, open func(FileInfo) (FileReaderAt, error)) *parallelHashFiles {
+	ret := &parallelHashFiles{
+		pieceLength: pieceLength,
+		open:        open,
+	}
+	var offset int64
+	for _, fi := range fis {
+		f := &parallelHashFile{
+			fi:     fi,
+			offset: offset,
+		}
+		if fi.Length > 0 {
+			f.pending = int((offset+fi.Length-1)/pieceLength-offset/pieceLength) + 1
+		}
+		offset += fi.Length
+		ret.files = append(ret.files, f)
+		ret.ends = append(ret.ends, offset)
+	}
+	return ret
+}
+
+// Appends the hash of the piece to b, which must have enough capacity. Returns the number of bytes
+// hashed.
+func (me *parallelHashFiles) hashPiece(ctx context.Context, piece int, total int64, b, buf []byte) (int64, error) {
+	begin := int64(piece) * me.pieceLength
+	end := begin + me.pieceLength
+	if end > total {
+		end = total
+	}
+	h := sha1.New()
+	// The first file that ends after the start of the piece.
+	for i := sort.Search(len(me.ends), func(i int) bool {
+		return me.ends[i] > begin
+	}); i < len(me.files) && me.files[i].offset < end; i++ {
+		f := me.files[i]
+		if f.fi.Length == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		r, err := f.reader(me.open)
+		if err != nil {
+			return 0, err
+		}
+		off := begin - f.offset
+		if off < 0 {
+			off = 0
+		}
+		n := end - f.offset - off
+		if n > f.fi.Length-off {
+			n = f.fi.Length - off
+		}
+		written, err := io.CopyBuffer(h, io.NewSectionReader(r, off, n), buf)
+		if written != n {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, fmt.Errorf("error reading %v: %w", f.fi, err)
+		}
+		if err := f.release(); err != nil {
+			return 0, fmt.Errorf("error closing %v: %w", f.fi, err)
+		}
+	}
+	h.Sum(b)
+	return end - begin, nil
+}
+
+func (me *parallelHashFile) reader(open func(FileInfo) (FileReaderAt, error)) (FileReaderAt, error) {
+	if me.fi.IsPad() {
+		return zeroReader{}, nil
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.r == nil {
+		r, err := open(me.fi)
+		if err != nil {
+			return nil, fmt.Errorf("error opening %v: %w", me.fi, err)
+		}
+		me.r = r
+	}
+	return me.r, nil
+}
+
+// Closes the file once all the pieces overlapping it have been hashed.
+func (me *parallelHashFile) release() error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.pending--
+	if me.pending != 0 || me.r == nil {
+		return nil
+	}
+	err := me.r.Close()
+	me.r = nil
+	return err
+}
+
+// Closes any files left open due to an error.
+func (me *parallelHashFiles) closeAll() {
+	for _, f := range me.files {
+		f.mu.Lock()
+		if f.r != nil {
+			f.r.Close()
+			f.r = nil
+		}
+		f.mu.Unlock()
+	}
+}
+
+// The contents of padding files.
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func (me zeroReader) ReadAt(b []byte, _ int64) (int, error) {
+	return me.Read(b)
+}
+
+func (zeroReader) Close() error {
+	return nil
+}
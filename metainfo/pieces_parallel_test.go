@@ -0,0 +1,90 @@
+package metainfo
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePiecesParallelMatchesSequential(t *testing.T) {
+	root := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+	for name, length := range map[string]int{
+		"a":       1000,
+		"b/empty": 0,
+		"b/c":     1,
+		"d":       4096,
+		"e":       10000,
+	} {
+		b := make([]byte, length)
+		rnd.Read(b)
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, b, 0o644))
+	}
+	for _, pieceLength := range []int64{1, 7, 1024, 4096, 1 << 20} {
+		seq := Info{PieceLength: pieceLength}
+		require.NoError(t, seq.BuildFromFilePath(root))
+		var lastHashed int64
+		par := Info{PieceLength: pieceLength}
+		require.NoError(t, par.BuildFromFilePathParallel(context.Background(), root, BuildFromFilePathOpts{GeneratePiecesOpts: GeneratePiecesOpts{
+			Workers: 3,
+			Progress: func(hashed, total int64) {
+				assert.True(t, hashed > lastHashed)
+				lastHashed = hashed
+			},
+		}}))
+		assert.Equal(t, seq.Pieces, par.Pieces, "piece length %v", pieceLength)
+		assert.EqualValues(t, seq.TotalLength(), lastHashed)
+	}
+}
+
+func TestAlignFilesToPieces(t *testing.T) {
+	root := t.TempDir()
+	for name, length := range map[string]int{"a": 5, "b": 8, "c": 3} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), make([]byte, length), 0o644))
+	}
+	var info Info
+	info.PieceLength = 4
+	require.NoError(t, info.BuildFromFilePathParallel(context.Background(), root, BuildFromFilePathOpts{
+		PieceAligned: true,
+	}))
+	var offset int64
+	var names []string
+	for _, fi := range info.Files {
+		if fi.IsPad() {
+			assert.Equal(t, []string{".pad", "3"}, fi.Path)
+		} else {
+			assert.Zero(t, offset%info.PieceLength)
+			names = append(names, fi.Path[0])
+		}
+		offset += fi.Length
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+	assert.EqualValues(t, 19, info.TotalLength())
+	assert.Equal(t, 5, info.NumPieces())
+	assert.NoError(t, info.Validate())
+	seq := info
+	seq.Pieces = nil
+	require.NoError(t, seq.GeneratePieces(func(fi FileInfo) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(root, fi.Path[0]))
+	}))
+	assert.Equal(t, seq.Pieces, info.Pieces)
+}
+
+func TestGeneratePiecesParallelCancelled(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a"), make([]byte, 1<<16), 0o644))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var info Info
+	info.PieceLength = 1024
+	err := info.BuildFromFilePathParallel(ctx, root, BuildFromFilePathOpts{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
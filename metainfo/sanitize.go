@@ -0,0 +1,159 @@
+package metainfo
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// The longest path component, in bytes, that's produced by sanitization. This is the limit for
+// most filesystems.
+const MaxSanitizedComponentLength = 255
+
+// Names that Windows reserves for devices, regardless of extension.
+var windowsReservedNames = func() map[string]bool {
+	ret := map[string]bool{"con": true, "prn": true, "aux": true, "nul": true}
+	for i := 1; i <= 9; i++ {
+		ret[fmt.Sprintf("com%d", i)] = true
+		ret[fmt.Sprintf("lpt%d", i)] = true
+	}
+	return ret
+}()
+
+// Maps a single path component from a torrent to one that's safe to use on common filesystems.
+// Invalid UTF-8 is replaced, control characters and characters that aren't allowed on Windows
+// become '_', trailing dots and spaces are removed, reserved device names are suffixed with '_',
+// and the result is truncated to MaxSanitizedComponentLength, keeping any extension. The result is
+// never empty, "." or "..". The same input always produces the same output.
+func SanitizePathComponent(c string) string {
+	c = strings.ToValidUTF8(c, "�")
+	c = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, c)
+	c = strings.TrimRight(c, ". ")
+	if c == "" {
+		return "_"
+	}
+	// Windows considers everything up to the first dot.
+	if i := strings.IndexByte(c+".", '.'); windowsReservedNames[strings.ToLower(strings.TrimRight(c[:i], " "))] {
+		c = c[:i] + "_" + c[i:]
+	}
+	base, ext := splitExt(c)
+	return joinTruncated(base, "", ext, MaxSanitizedComponentLength)
+}
+
+// Splits c before the last '.', unless that would leave an empty base.
+func splitExt(c string) (base, ext string) {
+	ext = path.Ext(c)
+	if ext == c {
+		return c, ""
+	}
+	return c[:len(c)-len(ext)], ext
+}
+
+// Joins base, suffix and ext, truncating base so the result is at most max bytes. Long extensions
+// are considered part of the base.
+func joinTruncated(base, suffix, ext string, max int) string {
+	if len(ext) > max/8 {
+		base, ext = base+ext, ""
+	}
+	if n := max - len(suffix) - len(ext); len(base) > n {
+		base = base[:n]
+		// Don't leave a partial rune, or anything Windows would strip.
+		for len(base) > 0 && !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		base = strings.TrimRight(base, ". ")
+		if base == "" {
+			base = "_"
+		}
+	}
+	return base + suffix + ext
+}
+
+// Returns safe relative paths for the files in UpvertedFiles, preferring path.utf-8. Components
+// are sanitized with SanitizePathComponent. Names that collide, ignoring case, or that are both a
+// file and a directory, get a numbered suffix. Padding files have nil paths.
+func (info *Info) SanitizedFilePaths() (ret [][]string) {
+	if !info.IsDir() {
+		return [][]string{{info.SanitizedName()}}
+	}
+	root := newSanitizeDir()
+	for _, fi := range info.Files {
+		if fi.IsPad() {
+			ret = append(ret, nil)
+			continue
+		}
+		d := root
+		comps := fi.BestPath()
+		var out []string
+		for i, c := range comps {
+			if i == len(comps)-1 {
+				out = append(out, d.file(c))
+			} else {
+				var name string
+				name, d = d.dir(c)
+				out = append(out, name)
+			}
+		}
+		if len(out) == 0 {
+			out = append(out, root.file(""))
+		}
+		ret = append(ret, out)
+	}
+	return
+}
+
+// The sanitized BestName, for use as the torrent's directory, or file for single-file torrents.
+func (info *Info) SanitizedName() string {
+	return SanitizePathComponent(info.BestName())
+}
+
+// Assigns unique names within a directory during SanitizedFilePaths.
+type sanitizeDir struct {
+	// Case-folded names that have been assigned.
+	taken map[string]bool
+	// Subdirectories by their original component.
+	dirs map[string]*sanitizeDir
+	// Assigned names of subdirectories by their original component.
+	dirNames map[string]string
+}
+
+func newSanitizeDir() *sanitizeDir {
+	return &sanitizeDir{
+		taken:    make(map[string]bool),
+		dirs:     make(map[string]*sanitizeDir),
+		dirNames: make(map[string]string),
+	}
+}
+
+// Returns the name and state for a subdirectory, reusing it if the original component was seen
+// before.
+func (me *sanitizeDir) dir(c string) (string, *sanitizeDir) {
+	if d, ok := me.dirs[c]; ok {
+		return me.dirNames[c], d
+	}
+	name := me.unique(SanitizePathComponent(c))
+	d := newSanitizeDir()
+	me.dirs[c] = d
+	me.dirNames[c] = name
+	return name, d
+}
+
+func (me *sanitizeDir) file(c string) string {
+	return me.unique(SanitizePathComponent(c))
+}
+
+func (me *sanitizeDir) unique(name string) string {
+	ret := name
+	for i := 1; me.taken[strings.ToLower(ret)]; i++ {
+		base, ext := splitExt(name)
+		ret = joinTruncated(base, fmt.Sprintf(" (%d)", i), ext, MaxSanitizedComponentLength)
+	}
+	me.taken[strings.ToLower(ret)] = true
+	return ret
+}
@@ -0,0 +1,57 @@
+package metainfo
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizePathComponent(t *testing.T) {
+	for in, out := range map[string]string{
+		"ok.txt":       "ok.txt",
+		"":             "_",
+		".":            "_",
+		"..":           "_",
+		"a/b":          "a_b",
+		"x\x00y\n":     "x_y_",
+		`what?<>:|*"\`: "what________",
+		"trailing. . ": "trailing",
+		"CON":          "CON_",
+		"con.txt":      "con_.txt",
+		"lpt9.tar.gz":  "lpt9_.tar.gz",
+		"console":      "console",
+		"\xffbad utf8": "�bad utf8",
+	} {
+		assert.Equal(t, out, SanitizePathComponent(in), "%q", in)
+	}
+	long := SanitizePathComponent(strings.Repeat("é", 200) + ".mkv")
+	assert.True(t, len(long) <= MaxSanitizedComponentLength)
+	assert.True(t, utf8.ValidString(long))
+	assert.True(t, strings.HasSuffix(long, ".mkv"))
+}
+
+func TestSanitizedFilePaths(t *testing.T) {
+	info := Info{
+		Name: "t",
+		Files: []FileInfo{
+			{Path: []string{"Dir", "a"}},
+			{Path: []string{"dir", "a"}},
+			{Path: []string{"Dir", "A"}},
+			{Path: []string{"x.txt"}},
+			{Path: []string{"X.TXT", "y"}},
+			{Path: []string{"raw"}, PathUtf8: []string{"utf8"}},
+		},
+	}
+	assert.Equal(t, [][]string{
+		{"Dir", "a"},
+		{"dir (1)", "a"},
+		{"Dir", "A (1)"},
+		{"x.txt"},
+		{"X (1).TXT", "y"},
+		{"utf8"},
+	}, info.SanitizedFilePaths())
+	info = Info{Name: "nul", Length: 1}
+	assert.Equal(t, [][]string{{"nul_"}}, info.SanitizedFilePaths())
+}
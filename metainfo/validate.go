@@ -0,0 +1,127 @@
+package metainfo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Errors returned by Info.Validate. They're wrapped with more detail, so test for them with
+// errors.Is.
+var (
+	ErrBadPiecesLength    = errors.New("pieces length is not a multiple of the hash size")
+	ErrBadPieceLength     = errors.New("bad piece length")
+	ErrPieceCountMismatch = errors.New("piece count and total length are at odds")
+	ErrLengthAndFiles     = errors.New("length and files are mutually exclusive")
+	ErrBadLength          = errors.New("bad length")
+	ErrEmptyPath          = errors.New("empty file path")
+	ErrBadPathComponent   = errors.New("bad path component")
+	ErrBadName            = errors.New("bad name")
+)
+
+// Describes a problem with one of the files in an Info.
+type FileError struct {
+	// Index into Info.Files.
+	Index int
+	Path  []string
+	Err   error
+}
+
+func (me FileError) Error() string {
+	return fmt.Sprintf("file %d %q: %s", me.Index, me.Path, me.Err)
+}
+
+func (me FileError) Unwrap() error {
+	return me.Err
+}
+
+// Checks that the info is internally consistent, and that its names can't refer outside the
+// torrent's directory. Info received from peers should be validated before being used.
+func (info *Info) Validate() error {
+	if len(info.Pieces)%HashSize != 0 {
+		return fmt.Errorf("%w: %d", ErrBadPiecesLength, len(info.Pieces))
+	}
+	// Piece offsets and lengths are 32 bits in the peer protocol.
+	if info.PieceLength < 0 || info.PieceLength > math.MaxInt32 {
+		return fmt.Errorf("%w: %d", ErrBadPieceLength, info.PieceLength)
+	}
+	if info.Length != 0 && len(info.Files) != 0 {
+		return ErrLengthAndFiles
+	}
+	for _, name := range []string{info.Name, info.NameUtf8} {
+		if err := validateName(name); err != nil {
+			return err
+		}
+	}
+	if info.Length < 0 {
+		return fmt.Errorf("%w: %d", ErrBadLength, info.Length)
+	}
+	total := info.Length
+	for i, fi := range info.Files {
+		if err := fi.validate(); err != nil {
+			return FileError{i, fi.Path, err}
+		}
+		if fi.Length > math.MaxInt64-total {
+			return FileError{i, fi.Path, fmt.Errorf("%w: total length overflows", ErrBadLength)}
+		}
+		total += fi.Length
+	}
+	if info.PieceLength == 0 {
+		if total != 0 {
+			return fmt.Errorf("%w: zero with nonzero total length", ErrBadPieceLength)
+		}
+		if len(info.Pieces) != 0 {
+			return fmt.Errorf("%w: pieces given for zero total length", ErrPieceCountMismatch)
+		}
+		return nil
+	}
+	expected := total / info.PieceLength
+	if total%info.PieceLength != 0 {
+		expected++
+	}
+	if int64(info.NumPieces()) != expected {
+		return fmt.Errorf("%w: have %d pieces, expected %d", ErrPieceCountMismatch, info.NumPieces(), expected)
+	}
+	return nil
+}
+
+func (fi *FileInfo) validate() error {
+	if fi.Length < 0 {
+		return fmt.Errorf("%w: %d", ErrBadLength, fi.Length)
+	}
+	if len(fi.Path) == 0 {
+		return ErrEmptyPath
+	}
+	for _, p := range [][]string{fi.Path, fi.PathUtf8} {
+		for _, c := range p {
+			if err := validatePathComponent(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// The name is advisory and can be empty, but otherwise it's used as a path component.
+func validateName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if err := validatePathComponent(name); err != nil {
+		return fmt.Errorf("%w: %s", ErrBadName, err)
+	}
+	return nil
+}
+
+// Backslashes are allowed, as they're only separators on some systems. Storage sanitizes paths for
+// those.
+func validatePathComponent(c string) error {
+	switch {
+	case c == "", c == ".", c == "..":
+	case strings.ContainsAny(c, "/\x00"):
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrBadPathComponent, c)
+}
@@ -0,0 +1,55 @@
+package metainfo
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoValidate(t *testing.T) {
+	ok := func() Info {
+		return Info{
+			Name:        "a",
+			PieceLength: 4,
+			Pieces:      make([]byte, 2*HashSize),
+			Files: []FileInfo{
+				{Length: 3, Path: []string{"b", "c"}},
+				{Length: 2, Path: []string{"d"}},
+			},
+		}
+	}
+	info := ok()
+	assert.NoError(t, info.Validate())
+	for _, tc := range []struct {
+		modify func(*Info)
+		err    error
+	}{
+		{func(i *Info) { i.Pieces = i.Pieces[1:] }, ErrBadPiecesLength},
+		{func(i *Info) { i.Pieces = i.Pieces[HashSize:] }, ErrPieceCountMismatch},
+		{func(i *Info) { i.PieceLength = -1 }, ErrBadPieceLength},
+		{func(i *Info) { i.PieceLength = math.MaxInt32 + 1 }, ErrBadPieceLength},
+		{func(i *Info) { i.Length = 5 }, ErrLengthAndFiles},
+		{func(i *Info) { i.Name = ".." }, ErrBadName},
+		{func(i *Info) { i.NameUtf8 = "x/y" }, ErrBadName},
+		{func(i *Info) { i.Files[0].Path = nil }, ErrEmptyPath},
+		{func(i *Info) { i.Files[0].Path = []string{"..", "etc"} }, ErrBadPathComponent},
+		{func(i *Info) { i.Files[1].PathUtf8 = []string{"/etc/passwd"} }, ErrBadPathComponent},
+		{func(i *Info) { i.Files[1].Length = -1 }, ErrBadLength},
+	} {
+		info := ok()
+		tc.modify(&info)
+		err := info.Validate()
+		assert.True(t, errors.Is(err, tc.err), "%v", err)
+	}
+	info = ok()
+	info.Files[0].Path = []string{`b\c`}
+	assert.NoError(t, info.Validate())
+	info = ok()
+	info.Files[1].Path = []string{"."}
+	var fe FileError
+	if assert.True(t, errors.As(info.Validate(), &fe)) {
+		assert.Equal(t, 1, fe.Index)
+	}
+}
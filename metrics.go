@@ -0,0 +1,167 @@
+package torrent
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Instrumentation counters for a single Client. Metrics implements expvar.Var, so it can be
+// published with expvar.Publish, and is exported in the Prometheus text format by
+// Client.WritePrometheus.
+type Metrics struct {
+	// Miscellaneous counts keyed by description.
+	torrent expvar.Map
+
+	peersAddedBySource expvar.Map
+
+	pieceHashedCorrect    expvar.Int
+	pieceHashedNotCorrect expvar.Int
+
+	completedHandshakeConnectionFlags expvar.Map
+	// Count of connections to peer with same client ID.
+	connsToSelf        expvar.Int
+	receivedKeepalives expvar.Int
+	postedKeepalives   expvar.Int
+	// Requests received for pieces we don't have.
+	requestsReceivedForMissingPieces expvar.Int
+	requestedChunkLengths            expvar.Map
+
+	messageTypesReceived expvar.Map
+
+	// Track the effectiveness of Torrent.connPieceInclinationPool.
+	pieceInclinationsReused expvar.Int
+	pieceInclinationsNew    expvar.Int
+	pieceInclinationsPut    expvar.Int
+}
+
+var _ expvar.Var = (*Metrics)(nil)
+
+// A Prometheus metric family built from a Metrics field.
+type metricFamily struct {
+	name string
+	help string
+	// The label for the keys of expvar.Map fields, or the label and value to distinguish expvar.Int
+	// fields that share a family.
+	label      string
+	labelValue string
+	value      func(*Metrics) expvar.Var
+}
+
+// Ordered by name so that samples of the same family are written together.
+var metricFamilies = []metricFamily{
+	{"torrent_client_conns_to_self_total", "Connections to peers with our peer ID.", "", "", func(m *Metrics) expvar.Var { return &m.connsToSelf }},
+	{"torrent_client_events_total", "Miscellaneous client events.", "event", "", func(m *Metrics) expvar.Var { return &m.torrent }},
+	{"torrent_client_handshakes_completed_total", "Completed handshakes by connection flags.", "flags", "", func(m *Metrics) expvar.Var { return &m.completedHandshakeConnectionFlags }},
+	{"torrent_client_keepalives_posted_total", "Keepalives sent to peers.", "", "", func(m *Metrics) expvar.Var { return &m.postedKeepalives }},
+	{"torrent_client_keepalives_received_total", "Keepalives received from peers.", "", "", func(m *Metrics) expvar.Var { return &m.receivedKeepalives }},
+	{"torrent_client_messages_received_total", "Peer protocol messages received by type.", "type", "", func(m *Metrics) expvar.Var { return &m.messageTypesReceived }},
+	{"torrent_client_peers_added_total", "Peers added by source.", "source", "", func(m *Metrics) expvar.Var { return &m.peersAddedBySource }},
+	{"torrent_client_piece_inclinations_total", "Piece inclination pool operations.", "op", "new", func(m *Metrics) expvar.Var { return &m.pieceInclinationsNew }},
+	{"torrent_client_piece_inclinations_total", "", "op", "put", func(m *Metrics) expvar.Var { return &m.pieceInclinationsPut }},
+	{"torrent_client_piece_inclinations_total", "", "op", "reused", func(m *Metrics) expvar.Var { return &m.pieceInclinationsReused }},
+	{"torrent_client_pieces_hashed_total", "Pieces hashed by result, excluding initial checks.", "result", "correct", func(m *Metrics) expvar.Var { return &m.pieceHashedCorrect }},
+	{"torrent_client_pieces_hashed_total", "", "result", "incorrect", func(m *Metrics) expvar.Var { return &m.pieceHashedNotCorrect }},
+	{"torrent_client_requested_chunk_lengths_total", "Chunk requests received by length.", "length", "", func(m *Metrics) expvar.Var { return &m.requestedChunkLengths }},
+	{"torrent_client_requests_received_for_missing_pieces_total", "Requests received for pieces we don't have.", "", "", func(m *Metrics) expvar.Var { return &m.requestsReceivedForMissingPieces }},
+}
+
+// Returns the counters as a JSON object, like the expvar globals they replace.
+func (m *Metrics) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, f := range metricFamilies {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		key := f.name
+		if f.labelValue != "" {
+			key += "_" + f.labelValue
+		}
+		fmt.Fprintf(&b, "%q: %s", key, f.value(m).String())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Writes the counters in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	pw := prometheusWriter{w: w}
+	for _, f := range metricFamilies {
+		pw.family(f.name, f.help, "counter")
+		switch v := f.value(m).(type) {
+		case *expvar.Int:
+			if f.label == "" {
+				pw.sample(f.name, nil, v.Value())
+			} else {
+				pw.sample(f.name, []string{f.label, f.labelValue}, v.Value())
+			}
+		case *expvar.Map:
+			// Do visits keys in sorted order.
+			v.Do(func(kv expvar.KeyValue) {
+				if i, ok := kv.Value.(*expvar.Int); ok {
+					pw.sample(f.name, []string{f.label, kv.Key}, i.Value())
+				}
+			})
+		}
+	}
+	return pw.err
+}
+
+// Writes Prometheus text format, keeping the first error.
+type prometheusWriter struct {
+	w    io.Writer
+	last string
+	err  error
+}
+
+func (me *prometheusWriter) printf(format string, args ...interface{}) {
+	if me.err != nil {
+		return
+	}
+	_, me.err = fmt.Fprintf(me.w, format, args...)
+}
+
+// Writes the HELP and TYPE lines unless the family was the last one written.
+func (me *prometheusWriter) family(name, help, typ string) {
+	if name == me.last {
+		return
+	}
+	me.last = name
+	me.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// Labels are name, value pairs.
+func (me *prometheusWriter) sample(name string, labels []string, value interface{}) {
+	if len(labels) == 0 {
+		me.printf("%s %v\n", name, value)
+		return
+	}
+	var b strings.Builder
+	for i := 0; i < len(labels); i += 2 {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(prometheusLabelEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	me.printf("%s{%s} %v\n", name, b.String(), value)
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Returns the Client's instrumentation counters.
+func (cl *Client) Metrics() *Metrics {
+	return &cl.metrics
+}
+
+// Sorts torrents for stable metric output.
+func sortTorrentsByInfoHash(ts []*Torrent) {
+	sort.Slice(ts, func(i, j int) bool {
+		return ts[i].infoHash.HexString() < ts[j].infoHash.HexString()
+	})
+}
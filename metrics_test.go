@@ -0,0 +1,29 @@
+package torrent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsWritePrometheus(t *testing.T) {
+	var m Metrics
+	m.torrent.Add(`say "hi"`, 2)
+	m.pieceHashedCorrect.Add(3)
+	m.connsToSelf.Add(1)
+	var b strings.Builder
+	require.NoError(t, m.WritePrometheus(&b))
+	out := b.String()
+	assert.Contains(t, out, "torrent_client_events_total{event=\"say \\\"hi\\\"\"} 2\n")
+	assert.Contains(t, out, "torrent_client_pieces_hashed_total{result=\"correct\"} 3\n")
+	assert.Contains(t, out, "torrent_client_pieces_hashed_total{result=\"incorrect\"} 0\n")
+	assert.Contains(t, out, "torrent_client_conns_to_self_total 1\n")
+	assert.Equal(t, 1, strings.Count(out, "# TYPE torrent_client_pieces_hashed_total counter\n"))
+
+	var v map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(m.String()), &v))
+	assert.EqualValues(t, 3, v["torrent_client_pieces_hashed_total_correct"])
+}
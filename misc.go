@@ -1,7 +1,6 @@
 package torrent
 
 import (
-	"errors"
 	"net"
 
 	"github.com/anacrolix/missinggo"
@@ -82,22 +81,6 @@ func torrentRequestOffset(torrentLength, pieceSize int64, r request) (off int64)
 	return
 }
 
-func validateInfo(info *metainfo.Info) error {
-	if len(info.Pieces)%20 != 0 {
-		return errors.New("pieces has invalid length")
-	}
-	if info.PieceLength == 0 {
-		if info.TotalLength() != 0 {
-			return errors.New("zero piece length")
-		}
-	} else {
-		if int((info.TotalLength()+info.PieceLength-1)/info.PieceLength) != info.NumPieces() {
-			return errors.New("piece count and file lengths are at odds")
-		}
-	}
-	return nil
-}
-
 func chunkIndexSpec(index pp.Integer, pieceLength, chunkSize pp.Integer) chunkSpec {
 	ret := chunkSpec{pp.Integer(index) * chunkSize, chunkSize}
 	if ret.Begin+ret.Length > pieceLength {
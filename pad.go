@@ -0,0 +1,96 @@
+package torrent
+
+import (
+	"io"
+	"sort"
+
+	"github.com/anacrolix/missinggo/bitmap"
+
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// A range of torrent data belonging to a BEP 47 padding file. Padding data is zeros, so it's never
+// requested from peers, and it's not read from or written to storage where that can be avoided.
+type padRegion struct {
+	begin, end int64
+}
+
+func (t *Torrent) initPadRegions() {
+	t.padRegions = nil
+	var offset int64
+	for _, fi := range t.info.UpvertedFiles() {
+		if fi.IsPad() && fi.Length > 0 {
+			t.padRegions = append(t.padRegions, padRegion{offset, offset + fi.Length})
+		}
+		offset += fi.Length
+	}
+}
+
+// Returns the first padding region that ends after off, if any.
+func (t *Torrent) nextPadRegion(off int64) (padRegion, bool) {
+	i := sort.Search(len(t.padRegions), func(i int) bool {
+		return t.padRegions[i].end > off
+	})
+	if i == len(t.padRegions) {
+		return padRegion{}, false
+	}
+	return t.padRegions[i], true
+}
+
+// Marks the chunks of the piece that lie entirely in padding as dirty, so they're never requested.
+func (t *Torrent) dirtyPadChunks(piece pieceIndex) {
+	if len(t.padRegions) == 0 {
+		return
+	}
+	p := &t.pieces[piece]
+	offset := p.torrentBeginOffset()
+	for i := pp.Integer(0); i < p.numChunks(); i++ {
+		cs := p.chunkIndexSpec(i)
+		begin := offset + int64(cs.Begin)
+		if pr, ok := t.nextPadRegion(begin); ok && pr.begin <= begin && pr.end >= begin+int64(cs.Length) {
+			p.dirtyChunks.Add(bitmap.BitIndex(i))
+		}
+	}
+}
+
+// Wraps the storage for a piece so that padding reads as zeros.
+type padZeroReaderAt struct {
+	t *Torrent
+	r io.ReaderAt
+	// The torrent offset corresponding to offset zero in r.
+	offset int64
+}
+
+func (me padZeroReaderAt) ReadAt(b []byte, off int64) (n int, err error) {
+	for len(b) != 0 {
+		begin := me.offset + off
+		pr, ok := me.t.nextPadRegion(begin)
+		if ok && pr.begin <= begin {
+			m := int64(len(b))
+			if rem := pr.end - begin; m > rem {
+				m = rem
+			}
+			for j := range b[:m] {
+				b[j] = 0
+			}
+			n += int(m)
+			off += m
+			b = b[m:]
+			continue
+		}
+		m := len(b)
+		if ok && pr.begin-begin < int64(m) {
+			m = int(pr.begin - begin)
+		}
+		var m1 int
+		m1, err = me.r.ReadAt(b[:m], off)
+		n += m1
+		if err != nil && !(err == io.EOF && m1 == m && m < len(b)) {
+			return
+		}
+		err = nil
+		off += int64(m1)
+		b = b[m1:]
+	}
+	return
+}
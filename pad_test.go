@@ -0,0 +1,72 @@
+package torrent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+func TestPadZeroReaderAt(t *testing.T) {
+	tor := &Torrent{
+		padRegions: []padRegion{{2, 4}, {6, 9}},
+	}
+	data := bytes.NewReader([]byte("0123456789"))
+	r := padZeroReaderAt{tor, data, 1}
+	for _, _case := range []struct {
+		off  int64
+		n    int
+		want string
+	}{
+		{0, 9, "0\x00\x0034\x00\x00\x008"},
+		{1, 1, "\x00"},
+		{2, 3, "\x0034"},
+		{6, 3, "\x00\x008"},
+	} {
+		b := make([]byte, _case.n)
+		n, err := r.ReadAt(b, _case.off)
+		assert.NoError(t, err)
+		assert.Equal(t, _case.n, n)
+		assert.Equal(t, _case.want, string(b))
+	}
+}
+
+// Chunks entirely within padding are never requested, including after the chunk size changes.
+func TestPadChunksNotRequested(t *testing.T) {
+	info := metainfo.Info{
+		Name:        "padded",
+		PieceLength: 8,
+		Pieces:      make([]byte, 2*20),
+		Files: []metainfo.FileInfo{
+			{Path: []string{"a"}, Length: 3},
+			{Path: []string{".pad", "5"}, Length: 5, Attr: "p"},
+			{Path: []string{"b"}, Length: 8},
+		},
+	}
+	infoBytes, err := bencode.Marshal(info)
+	require.NoError(t, err)
+	cl := newTestingClient(t, testingConfig(t))
+	tor, _, err := cl.AddTorrentSpec(&TorrentSpec{
+		InfoHash:  metainfo.HashBytes(infoBytes),
+		InfoBytes: infoBytes,
+		ChunkSize: 2,
+	})
+	require.NoError(t, err)
+	begins := func(piece pieceIndex) (ret []pp.Integer) {
+		cl.lock()
+		defer cl.unlock()
+		iterUndirtiedChunks(piece, tor, func(cs chunkSpec) bool {
+			ret = append(ret, cs.Begin)
+			return true
+		})
+		return
+	}
+	// The chunk at 2 is partly file data.
+	assert.Equal(t, []pp.Integer{0, 2}, begins(0))
+	assert.Equal(t, []pp.Integer{0, 2, 4, 6}, begins(1))
+}
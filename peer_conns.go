@@ -0,0 +1,103 @@
+package torrent
+
+import (
+	"net"
+
+	"github.com/anacrolix/log"
+	"github.com/anacrolix/missinggo/slices"
+	"github.com/anacrolix/torrent/mse"
+	"github.com/anacrolix/torrent/peerid"
+)
+
+// A read-only snapshot of an established peer connection. See Torrent.PeerConns.
+type PeerConnInfo struct {
+	ConnStatus
+	// From the peer's extended handshake, if it sent one.
+	PeerClientName string
+	// The client identified from the peer ID. The Style is peerid.Unknown if the ID doesn't follow a
+	// known convention.
+	PeerClient peerid.Client
+	// How we came to know of the peer.
+	Discovery PeerSource
+	// Such as "tcp4" or "udp6".
+	Network  string
+	Outgoing bool
+	// Encrypted is set if the connection uses RC4 throughout, and HeaderEncrypted if at least the
+	// handshake was obfuscated.
+	Encrypted       bool
+	HeaderEncrypted bool
+	// Our side of the connection state.
+	Choked     bool
+	Interested bool
+	// The peer's side of the connection state.
+	PeerChoked     bool
+	PeerInterested bool
+}
+
+// Returns snapshots of the torrent's established peer connections, best first.
+func (t *Torrent) PeerConns() (ret []PeerConnInfo) {
+	t.cl.rLock()
+	defer t.cl.rUnlock()
+	conns := t.connsAsSlice()
+	slices.Sort(conns, worseConn)
+	for _, c := range conns {
+		ret = append(ret, c.peerConnInfo())
+	}
+	return
+}
+
+func (cn *connection) peerConnInfo() PeerConnInfo {
+	client, _ := cn.PeerID.Client()
+	return PeerConnInfo{
+		ConnStatus:      cn.status(),
+		PeerClientName:  cn.PeerClientName,
+		PeerClient:      client,
+		Discovery:       cn.Discovery,
+		Network:         cn.network,
+		Outgoing:        cn.outgoing,
+		Encrypted:       cn.cryptoMethod == mse.CryptoMethodRC4,
+		HeaderEncrypted: cn.headerEncrypted,
+		Choked:          cn.Choked,
+		Interested:      cn.Interested,
+		PeerChoked:      cn.PeerChoked,
+		PeerInterested:  cn.PeerInterested,
+	}
+}
+
+// Closes the connection to the peer with the given remote address, as in PeerConnInfo.RemoteAddr.
+// Returns false if there's no such connection. The peer may be connected to again later, see
+// Client.BanPeerIP to prevent that.
+func (t *Torrent) DropPeerConn(remoteAddr string) bool {
+	t.cl.lock()
+	defer t.cl.unlock()
+	for c := range t.conns {
+		if c.remoteAddr.String() == remoteAddr {
+			t.dropConnection(c)
+			return true
+		}
+	}
+	return false
+}
+
+// Closes all connections to the IP, and refuses further connections to or from it. See
+// Client.BadPeerIPs.
+func (cl *Client) BanPeerIP(ip net.IP) {
+	cl.lock()
+	defer cl.unlock()
+	cl.banPeerIP(ip)
+	for _, t := range cl.torrents {
+		for c := range t.conns {
+			if c.remoteAddr.IP.Equal(ip) {
+				t.dropConnection(c)
+			}
+		}
+	}
+}
+
+// Allows connections to the IP again after Client.BanPeerIP, or a ban due to bad behaviour.
+func (cl *Client) UnbanPeerIP(ip net.IP) {
+	cl.lock()
+	defer cl.unlock()
+	cl.logger.WithDefaultLevel(log.Info).Printf("unbanning ip %v", ip)
+	delete(cl.badPeerIPs, ip.String())
+}
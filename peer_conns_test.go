@@ -0,0 +1,44 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerConnsDropAndBan(t *testing.T) {
+	seeder, leecher := testSeederLeecher(t, nil)
+	requireNumPeerConns(t, leecher, 1)
+	requireNumPeerConns(t, seeder, 1)
+	out := leecher.PeerConns()[0]
+	assert.True(t, out.Outgoing)
+	assert.Equal(t, seeder.cl.PeerID(), out.PeerID)
+	in := seeder.PeerConns()[0]
+	assert.False(t, in.Outgoing)
+	assert.Equal(t, PeerSourceIncoming, in.Discovery)
+	assert.Equal(t, leecher.cl.PeerID(), in.PeerID)
+
+	assert.False(t, leecher.DropPeerConn("127.0.0.1:1"))
+	assert.True(t, leecher.DropPeerConn(out.RemoteAddr))
+	requireNumPeerConns(t, leecher, 0)
+	requireNumPeerConns(t, seeder, 0)
+
+	leecher.AddClientPeer(seeder.cl)
+	requireNumPeerConns(t, seeder, 1)
+	loopback := net.ParseIP("127.0.0.1")
+	seeder.cl.BanPeerIP(loopback)
+	assert.Empty(t, seeder.PeerConns())
+	assert.Equal(t, []string{"127.0.0.1"}, seeder.cl.BadPeerIPs())
+	requireNumPeerConns(t, leecher, 0)
+	// The seeder refuses the leecher while it's banned.
+	leecher.AddClientPeer(seeder.cl)
+	assert.Never(t, func() bool { return len(seeder.PeerConns()) != 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	seeder.cl.UnbanPeerIP(loopback)
+	assert.Empty(t, seeder.cl.BadPeerIPs())
+	leecher.AddClientPeer(seeder.cl)
+	requireNumPeerConns(t, seeder, 1)
+	requireNumPeerConns(t, leecher, 1)
+}
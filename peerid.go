@@ -1,14 +1,36 @@
 package torrent
 
+import (
+	"encoding/hex"
+
+	"github.com/anacrolix/torrent/peerid"
+)
+
 // Peer client ID.
 type PeerID [20]byte
 
-// // Pretty prints the ID as hex, except parts that adher to the Peer ID
-// // Conventions of BEP 20.
-// func (me PeerID) String() string {
-// 	// if me[0] == '-' && me[7] == '-' {
-// 	// 	return string(me[:8]) + hex.EncodeToString(me[8:])
-// 	// }
-// 	// return hex.EncodeToString(me[:])
-// 	return fmt.Sprintf("%+q", me[:])
-// }
+// Encodes as hex, since IDs are mostly arbitrary bytes.
+func (me PeerID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(me[:])), nil
+}
+
+// Identifies the client that generated the ID. Returns false if the ID doesn't follow a known
+// convention.
+func (me PeerID) Client() (peerid.Client, bool) {
+	return peerid.Decode(me)
+}
+
+// Returns the first ClientConfig.RejectPeerClients pattern that matches the client that generated
+// the ID.
+func (cl *Client) rejectedPeerClient(id PeerID) (peerid.Pattern, bool) {
+	c, ok := id.Client()
+	if !ok {
+		return peerid.Pattern{}, false
+	}
+	for _, p := range cl.config.RejectPeerClients {
+		if p.Match(c) {
+			return p, true
+		}
+	}
+	return peerid.Pattern{}, false
+}
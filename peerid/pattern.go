@@ -0,0 +1,45 @@
+package peerid
+
+import (
+	"path"
+	"strings"
+)
+
+// Matches clients by name or code, and optionally by version.
+type Pattern struct {
+	// A client name such as "Transmission", or a code such as "TR". Names are matched without
+	// regard to case.
+	Client string
+	// Matched against Client.Version with path.Match, so "2.*" matches any 2.x version. An empty
+	// Version matches any version.
+	Version string
+}
+
+// Parses patterns of the form "client" or "client/version", such as "Xunlei" or "TR/2.9*".
+func ParsePattern(s string) (ret Pattern, err error) {
+	ret.Client = s
+	if i := strings.LastIndexByte(s, '/'); i != -1 {
+		ret.Client, ret.Version = s[:i], s[i+1:]
+	}
+	// Catch malformed globs now, rather than failing to match later.
+	_, err = path.Match(ret.Version, "")
+	return
+}
+
+func (me Pattern) String() string {
+	if me.Version == "" {
+		return me.Client
+	}
+	return me.Client + "/" + me.Version
+}
+
+func (me Pattern) Match(c Client) bool {
+	if me.Client != c.Code && (c.Name == "" || !strings.EqualFold(me.Client, c.Name)) {
+		return false
+	}
+	if me.Version == "" {
+		return true
+	}
+	ok, _ := path.Match(me.Version, c.Version)
+	return ok
+}
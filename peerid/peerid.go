@@ -0,0 +1,266 @@
+// Package peerid identifies the BitTorrent client that generated a peer ID, for the conventions
+// described in http://bittorrent.org/beps/bep_0020.html.
+package peerid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Style int
+
+const (
+	Unknown Style = iota
+	// "-XXVVVV-" followed by random bytes, such as "-TR2940-".
+	Azureus
+	// A client letter, up to 5 version characters, and dashes, such as "S58B-----".
+	Shadow
+	// "M" followed by a dash separated version, such as "M4-3-6--".
+	Mainline
+)
+
+func (me Style) String() string {
+	switch me {
+	case Azureus:
+		return "azureus"
+	case Shadow:
+		return "shadow"
+	case Mainline:
+		return "mainline"
+	default:
+		return "unknown"
+	}
+}
+
+// The client identified from a peer ID.
+type Client struct {
+	Style Style
+	// The client identifier in the peer ID. Two characters for the Azureus style, and one for the
+	// Shadow and Mainline styles.
+	Code string
+	// Empty if the Code isn't known.
+	Name    string
+	Version string
+}
+
+// Returns the name and version, falling back to the code if the client isn't known.
+func (me Client) String() string {
+	name := me.Name
+	if name == "" {
+		name = strconv.Quote(me.Code)
+	}
+	if me.Version == "" {
+		return name
+	}
+	return name + " " + me.Version
+}
+
+var azureusClients = map[string]string{
+	"7T": "aTorrent",
+	"AG": "Ares",
+	"A~": "Ares",
+	"AR": "Arctic",
+	"AT": "Artemis",
+	"AX": "BitPump",
+	"AZ": "Vuze",
+	"BB": "BitBuddy",
+	"BC": "BitComet",
+	"BF": "Bitflu",
+	"BI": "BiglyBT",
+	"BR": "BitRocket",
+	"BT": "BitTorrent",
+	"BW": "BitWombat",
+	"BX": "Bittorrent X",
+	"CD": "Enhanced CTorrent",
+	"DE": "Deluge",
+	"EB": "EBit",
+	"FD": "Free Download Manager",
+	"FG": "FlashGet",
+	"FT": "FoxTorrent",
+	"FW": "FrostWire",
+	"GR": "GetRight",
+	"GS": "GSTorrent",
+	"GT": "go.torrent",
+	"HK": "Hekate",
+	"HL": "Halite",
+	"KG": "KGet",
+	"KT": "KTorrent",
+	"LC": "LeechCraft",
+	"LP": "Lphant",
+	"LT": "libtorrent",
+	"lt": "rTorrent",
+	"LW": "LimeWire",
+	"MO": "MonoTorrent",
+	"MR": "Miro",
+	"NX": "Net Transport",
+	"OS": "OneSwarm",
+	"PD": "Pando",
+	"PI": "PicoTorrent",
+	"qB": "qBittorrent",
+	"QD": "QQDownload",
+	"RT": "Retriever",
+	"SD": "Thunder",
+	"SK": "spark",
+	"SZ": "Shareaza",
+	"TL": "Tribler",
+	"TR": "Transmission",
+	"TS": "Torrentstorm",
+	"TT": "TuoTu",
+	"UE": "µTorrent Embedded",
+	"UM": "µTorrent Mac",
+	"UT": "µTorrent",
+	"UW": "µTorrent Web",
+	"VG": "Vagaa",
+	"WD": "WebTorrent Desktop",
+	"WW": "WebTorrent",
+	"XF": "Xfplay",
+	"XL": "Xunlei",
+	"XT": "XanTorrent",
+	"XX": "Xtorrent",
+	"ZT": "ZipTorrent",
+}
+
+var shadowClients = map[byte]string{
+	'A': "ABC",
+	'O': "Osprey Permaseed",
+	'Q': "BTQueue",
+	'R': "Tribler",
+	'S': "Shadow",
+	'T': "BitTornado",
+	'U': "UPnP NAT Bit Torrent",
+}
+
+// Decodes the client from a peer ID. Returns false if the ID doesn't follow a recognized style.
+func Decode(id [20]byte) (ret Client, ok bool) {
+	switch {
+	case id[0] == '-' && id[7] == '-' && isAlnumOrTilde(id[1]) && isAlnumOrTilde(id[2]):
+		return decodeAzureus(id), true
+	case id[0] == 'M' && isDigit(id[1]):
+		return decodeMainline(id)
+	case shadowClients[id[0]] != "":
+		return decodeShadow(id)
+	}
+	return
+}
+
+func decodeAzureus(id [20]byte) Client {
+	ret := Client{
+		Style: Azureus,
+		Code:  string(id[1:3]),
+	}
+	ret.Name = azureusClients[ret.Code]
+	v := id[3:7]
+	switch ret.Code {
+	case "TR":
+		ret.Version = transmissionVersion(v)
+	case "UT", "UM", "UE", "UW":
+		// Three version characters and a build type.
+		ret.Version = joinVersion(v[:3], 3)
+		if v[3] == 'B' {
+			ret.Version += " beta"
+		}
+	default:
+		ret.Version = joinVersion(v, 2)
+	}
+	return ret
+}
+
+// Transmission uses "0VVV" for 0.x releases, and "MVVX" after that, where X may indicate a
+// development or beta build.
+func transmissionVersion(v []byte) (ret string) {
+	if v[0] == '0' && v[1] == '0' {
+		return "0." + string(v[2:4])
+	}
+	ret = fmt.Sprintf("%c.%s", v[0], v[1:3])
+	switch v[3] {
+	case 'Z', 'X':
+		ret += "+"
+	case 'b', 'B':
+		ret += " beta"
+	}
+	return
+}
+
+func decodeMainline(id [20]byte) (ret Client, ok bool) {
+	// Such as "M4-3-6--" or "M4-20-8-".
+	fields := strings.SplitN(string(id[1:]), "-", 4)
+	if len(fields) != 4 {
+		return
+	}
+	for _, f := range fields[:3] {
+		if f == "" || strings.TrimLeft(f, "0123456789") != "" {
+			return
+		}
+	}
+	return Client{
+		Style:   Mainline,
+		Code:    "M",
+		Name:    "BitTorrent",
+		Version: strings.Join(fields[:3], "."),
+	}, true
+}
+
+func decodeShadow(id [20]byte) (ret Client, ok bool) {
+	var parts []string
+	i := 1
+	for ; i < 6 && id[i] != '-'; i++ {
+		n := shadowValue(id[i])
+		if n < 0 {
+			return
+		}
+		parts = append(parts, strconv.Itoa(n))
+	}
+	// The version is followed by at least a couple of dashes.
+	if len(parts) == 0 || id[i] != '-' || id[i+1] != '-' {
+		return
+	}
+	return Client{
+		Style:   Shadow,
+		Code:    string(id[:1]),
+		Name:    shadowClients[id[0]],
+		Version: strings.Join(parts, "."),
+	}, true
+}
+
+// Joins the values of the version characters with dots, dropping trailing zeroes after the first
+// min components.
+func joinVersion(v []byte, min int) string {
+	parts := make([]string, 0, len(v))
+	for _, c := range v {
+		n := shadowValue(c)
+		if n < 0 {
+			// Not a version we understand, so show it as is.
+			return string(v)
+		}
+		parts = append(parts, strconv.Itoa(n))
+	}
+	for len(parts) > min && parts[len(parts)-1] == "0" {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+// The value of a version character in the Shadow style, which is also used by many Azureus style
+// clients. Returns -1 for characters that aren't allowed.
+func shadowValue(c byte) int {
+	switch {
+	case isDigit(c):
+		return int(c - '0')
+	case 'A' <= c && c <= 'Z':
+		return int(c-'A') + 10
+	case 'a' <= c && c <= 'z':
+		return int(c-'a') + 36
+	case c == '.':
+		return 62
+	}
+	return -1
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlnumOrTilde(c byte) bool {
+	return shadowValue(c) >= 0 && c != '.' || c == '~'
+}
@@ -0,0 +1,54 @@
+package peerid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func id(s string) (ret [20]byte) {
+	copy(ret[:], s)
+	return
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		id     string
+		ok     bool
+		client string
+	}{
+		{"-TR2940-xxxxxxxxxxxx", true, "Transmission 2.94"},
+		{"-TR0072-xxxxxxxxxxxx", true, "Transmission 0.72"},
+		{"-TR300Z-xxxxxxxxxxxx", true, "Transmission 3.00+"},
+		{"-qB4150-xxxxxxxxxxxx", true, "qBittorrent 4.1.5"},
+		{"-LT1200-xxxxxxxxxxxx", true, "libtorrent 1.2"},
+		{"-UT355B-xxxxxxxxxxxx", true, "µTorrent 3.5.5 beta"},
+		{"-ZZ1000-xxxxxxxxxxxx", true, `"ZZ" 1.0`},
+		{"S58B-----xxxxxxxxxxx", true, "Shadow 5.8.11"},
+		{"M4-3-6--xxxxxxxxxxxx", true, "BitTorrent 4.3.6"},
+		{"xxxxxxxxxxxxxxxxxxxx", false, ""},
+	} {
+		c, ok := Decode(id(tc.id))
+		assert.Equal(t, tc.ok, ok, tc.id)
+		if ok {
+			assert.Equal(t, tc.client, c.String(), tc.id)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	c, _ := Decode(id("-TR2940-xxxxxxxxxxxx"))
+	for s, match := range map[string]bool{
+		"TR":                true,
+		"transmission":      true,
+		"Transmission/2.9*": true,
+		"TR/3.*":            false,
+		"qB":                false,
+	} {
+		p, err := ParsePattern(s)
+		assert.NoError(t, err)
+		assert.Equal(t, match, p.Match(c), s)
+	}
+	_, err := ParsePattern("TR/[")
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package torrent
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/peerid"
+)
+
+func TestRejectPeerClients(t *testing.T) {
+	rejected := func(cl *Client) int64 {
+		v, _ := cl.metrics.torrent.Get("rejected peer client").(*expvar.Int)
+		if v == nil {
+			return 0
+		}
+		return v.Value()
+	}
+	// Test clients identify as go.torrent.
+	for _, _case := range []struct {
+		pattern string
+		reject  bool
+	}{
+		{"Transmission", false},
+		{"go.torrent/*", true},
+	} {
+		p, err := peerid.ParsePattern(_case.pattern)
+		require.NoError(t, err)
+		seeder, leecher := testSeederLeecher(t, func(cfg *ClientConfig) {
+			cfg.RejectPeerClients = []peerid.Pattern{p}
+		})
+		if !_case.reject {
+			requireNumPeerConns(t, leecher, 1)
+			assert.EqualValues(t, 0, rejected(leecher.cl))
+			continue
+		}
+		require.Eventually(t, func() bool { return rejected(leecher.cl) != 0 }, 5*time.Second, 10*time.Millisecond)
+		assert.Empty(t, leecher.PeerConns())
+		requireNumPeerConns(t, seeder, 0)
+	}
+}
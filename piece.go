@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/anacrolix/missinggo/bitmap"
@@ -50,6 +51,9 @@ type Piece struct {
 	hashing             bool
 	numVerifies         int64
 	storageCompletionOk bool
+	// Set while the completion of data downloaded from peers is handled, as opposed to data found
+	// when checking storage.
+	completingDownload bool
 
 	publicPieceState PieceState
 	priority         piecePriority
@@ -75,6 +79,14 @@ func (p *Piece) Storage() storage.Piece {
 	return p.t.storage.Piece(p.Info())
 }
 
+// Reads the piece from storage, with any padding read as zeros.
+func (p *Piece) readerAt() io.ReaderAt {
+	if len(p.t.padRegions) == 0 {
+		return p.Storage()
+	}
+	return padZeroReaderAt{p.t, p.Storage(), p.torrentBeginOffset()}
+}
+
 func (p *Piece) pendingChunkIndex(chunkIndex int) bool {
 	return !p.dirtyChunks.Contains(chunkIndex)
 }
@@ -210,6 +222,7 @@ func (p *Piece) SetPriority(prio piecePriority) {
 	p.t.cl.lock()
 	defer p.t.cl.unlock()
 	p.priority = prio
+	p.t.updateSequentialWindow()
 	p.t.updatePiecePriority(p.index)
 }
 
@@ -217,8 +230,23 @@ func (p *Piece) uncachedPriority() (ret piecePriority) {
 	if p.t.pieceComplete(p.index) || p.t.pieceQueuedForHash(p.index) || p.t.hashingPiece(p.index) {
 		return PiecePriorityNone
 	}
-	for _, f := range p.files {
-		ret.Raise(f.prio)
+	if s := p.t.sequential; s != nil {
+		// File and piece priorities only take effect when the window reaches the piece.
+		if s.pieces.Contains(int(p.index)) {
+			ret.Raise(PiecePriorityNormal)
+			for _, f := range p.files {
+				ret.Raise(f.prio)
+			}
+			ret.Raise(p.priority)
+		} else if p.priority > PiecePriorityNormal {
+			// Explicitly raised pieces, such as file edges, don't wait for the window.
+			ret.Raise(p.priority)
+		}
+	} else {
+		for _, f := range p.files {
+			ret.Raise(f.prio)
+		}
+		ret.Raise(p.priority)
 	}
 	if p.t.readerNowPieces.Contains(int(p.index)) {
 		ret.Raise(PiecePriorityNow)
@@ -229,7 +257,6 @@ func (p *Piece) uncachedPriority() (ret piecePriority) {
 	if p.t.readerReadaheadPieces.Contains(bitmap.BitIndex(p.index)) {
 		ret.Raise(PiecePriorityReadahead)
 	}
-	ret.Raise(p.priority)
 	return
 }
 
@@ -0,0 +1,122 @@
+package torrent
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+type torrentMetric struct {
+	name  string
+	help  string
+	typ   string
+	value func(*torrentMetricsSample) interface{}
+}
+
+type torrentMetricsSample struct {
+	t         *Torrent
+	name      string
+	stats     TorrentStats
+	haveInfo  bool
+	length    int64
+	completed int64
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var torrentMetrics = []torrentMetric{
+	{"torrent_info_available", "Whether the torrent info is known.", "gauge", func(s *torrentMetricsSample) interface{} { return boolMetric(s.haveInfo) }},
+	{"torrent_length_bytes", "Total length of the torrent data.", "gauge", func(s *torrentMetricsSample) interface{} { return s.length }},
+	{"torrent_completed_bytes", "Bytes of torrent data that are complete.", "gauge", func(s *torrentMetricsSample) interface{} { return s.completed }},
+	{"torrent_completion_ratio", "Fraction of the torrent data that is complete.", "gauge", func(s *torrentMetricsSample) interface{} {
+		if s.length == 0 {
+			return 0
+		}
+		return float64(s.completed) / float64(s.length)
+	}},
+	{"torrent_peers_active", "Established peer connections.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.ActivePeers }},
+	{"torrent_peers_half_open", "Outgoing peer connections being established.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.HalfOpenPeers }},
+	{"torrent_peers_pending", "Known peers not yet connected to.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.PendingPeers }},
+	{"torrent_peers_total", "Distinct known peer addresses.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.TotalPeers }},
+	{"torrent_seeders_connected", "Established connections to peers with all pieces.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.ConnectedSeeders }},
+	{"torrent_download_rate_bytes_per_second", "Useful data download rate in bytes per second.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.DownloadRate }},
+	{"torrent_upload_rate_bytes_per_second", "Data upload rate in bytes per second.", "gauge", func(s *torrentMetricsSample) interface{} { return s.stats.UploadRate }},
+	{"torrent_read_bytes_total", "Bytes read from peers, including protocol overhead.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.BytesRead.Int64() }},
+	{"torrent_read_useful_data_bytes_total", "Torrent data bytes read from peers that were wanted.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.BytesReadUsefulData.Int64() }},
+	{"torrent_written_bytes_total", "Bytes written to peers, including protocol overhead.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.BytesWritten.Int64() }},
+	{"torrent_written_data_bytes_total", "Torrent data bytes written to peers.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.BytesWrittenData.Int64() }},
+	{"torrent_chunks_read_wasted_total", "Chunks received that were already complete.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.ChunksReadWasted.Int64() }},
+	{"torrent_pieces_dirtied_good_total", "Pieces written to that passed verification.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.PiecesDirtiedGood.Int64() }},
+	{"torrent_pieces_dirtied_bad_total", "Pieces written to that failed verification.", "counter", func(s *torrentMetricsSample) interface{} { return s.stats.PiecesDirtiedBad.Int64() }},
+}
+
+func (cl *Client) torrentMetricsSamples() (ret []*torrentMetricsSample) {
+	cl.rLock()
+	defer cl.rUnlock()
+	ts := cl.torrentsAsSlice()
+	sortTorrentsByInfoHash(ts)
+	for _, t := range ts {
+		s := &torrentMetricsSample{
+			t:        t,
+			name:     t.name(),
+			stats:    t.statsLocked(),
+			haveInfo: t.haveInfo(),
+		}
+		if s.haveInfo {
+			s.length = *t.length
+			s.completed = t.bytesCompleted()
+		}
+		ret = append(ret, s)
+	}
+	return
+}
+
+// Writes the Client's Metrics, client-wide totals, and per-torrent metrics labelled by infohash and
+// name, in the Prometheus text exposition format. Rates are estimated over the last several
+// seconds.
+func (cl *Client) WritePrometheus(w io.Writer) error {
+	samples := cl.torrentMetricsSamples()
+	down, up := cl.TransferRates()
+
+	var buf bytes.Buffer
+	if err := cl.metrics.WritePrometheus(&buf); err != nil {
+		return err
+	}
+	pw := prometheusWriter{w: &buf}
+	pw.family("torrent_client_torrents", "Torrents in the client.", "gauge")
+	pw.sample("torrent_client_torrents", nil, len(samples))
+	pw.family("torrent_client_read_bytes_total", "Bytes read from all peers.", "counter")
+	pw.sample("torrent_client_read_bytes_total", nil, cl.stats.BytesRead.Int64())
+	pw.family("torrent_client_written_bytes_total", "Bytes written to all peers.", "counter")
+	pw.sample("torrent_client_written_bytes_total", nil, cl.stats.BytesWritten.Int64())
+	pw.family("torrent_client_download_rate_bytes_per_second", "Useful data download rate over all torrents in bytes per second.", "gauge")
+	pw.sample("torrent_client_download_rate_bytes_per_second", nil, down)
+	pw.family("torrent_client_upload_rate_bytes_per_second", "Data upload rate over all torrents in bytes per second.", "gauge")
+	pw.sample("torrent_client_upload_rate_bytes_per_second", nil, up)
+	for _, m := range torrentMetrics {
+		pw.family(m.name, m.help, m.typ)
+		for _, s := range samples {
+			pw.sample(m.name, []string{"infohash", s.t.infoHash.HexString(), "name", s.name}, m.value(s))
+		}
+	}
+	if pw.err != nil {
+		return pw.err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Serves Client.WritePrometheus, for scraping by Prometheus.
+func (cl *Client) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := cl.WritePrometheus(w); err != nil {
+			cl.logger.Printf("error writing prometheus metrics: %s", err)
+		}
+	})
+}
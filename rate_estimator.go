@@ -0,0 +1,107 @@
+package torrent
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	rateEstimatorInterval = time.Second
+	rateEstimatorBuckets  = 10
+)
+
+// Estimates a rate in units per second over a sliding window of the last rateEstimatorBuckets
+// intervals. Counts are accumulated into per-interval buckets, so adding and estimating are cheap
+// and the memory used is fixed.
+type rateEstimator struct {
+	mu sync.Mutex
+	// When the estimator was first used. Rates are averaged over less than the full window until
+	// it has elapsed, so new estimators aren't biased toward zero.
+	started time.Time
+	// The start of the interval accumulating into buckets[cur].
+	curStart time.Time
+	cur      int
+	buckets  [rateEstimatorBuckets]int64
+}
+
+func (me *rateEstimator) add(now time.Time, n int64) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.advance(now)
+	me.buckets[me.cur] += n
+}
+
+// Returns the estimated rate per second as of now.
+func (me *rateEstimator) rate(now time.Time) float64 {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.advance(now)
+	var sum int64
+	for _, n := range me.buckets {
+		sum += n
+	}
+	if sum == 0 {
+		return 0
+	}
+	// The completed intervals, and the elapsed part of the current one.
+	window := time.Duration(rateEstimatorBuckets-1)*rateEstimatorInterval + now.Sub(me.curStart)
+	if since := now.Sub(me.started); since < window {
+		window = since
+	}
+	// Don't extrapolate wildly from the first moments.
+	if window < rateEstimatorInterval {
+		window = rateEstimatorInterval
+	}
+	return float64(sum) / window.Seconds()
+}
+
+// Moves to the bucket containing now, clearing those passed over.
+func (me *rateEstimator) advance(now time.Time) {
+	if me.started.IsZero() {
+		me.started = now
+		me.curStart = now
+		return
+	}
+	steps := int(now.Sub(me.curStart) / rateEstimatorInterval)
+	if steps <= 0 {
+		return
+	}
+	me.curStart = me.curStart.Add(time.Duration(steps) * rateEstimatorInterval)
+	if steps > rateEstimatorBuckets {
+		steps = rateEstimatorBuckets
+	}
+	for i := 0; i < steps; i++ {
+		me.cur = (me.cur + 1) % rateEstimatorBuckets
+		me.buckets[me.cur] = 0
+	}
+}
+
+// Sliding-window estimates of data transfer rates, kept for each connection, and aggregated for
+// each Torrent and the Client.
+type transferRates struct {
+	// Useful torrent data received.
+	download rateEstimator
+	// Torrent data sent.
+	upload rateEstimator
+}
+
+// Returns the download and upload rates in bytes per second.
+func (me *transferRates) rates(now time.Time) (down, up float64) {
+	return me.download.rate(now), me.upload.rate(now)
+}
+
+// Applies f to the connection's rates, and those of the Torrent and Client once they're known,
+// like allStats.
+func (cn *connection) allRates(f func(*transferRates)) {
+	f(&cn.rates)
+	if cn.reconciledHandshakeStats {
+		f(&cn.t.rates)
+		f(&cn.t.cl.rates)
+	}
+}
+
+// Returns the Client's download and upload rates in bytes per second, over all torrents. The
+// download rate only counts useful data, and the upload rate only torrent data.
+func (cl *Client) TransferRates() (download, upload float64) {
+	return cl.rates.rates(time.Now())
+}
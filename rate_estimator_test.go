@@ -0,0 +1,25 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateEstimator(t *testing.T) {
+	var re rateEstimator
+	start := time.Unix(1000, 0)
+	assert.EqualValues(t, 0, re.rate(start))
+	// Rates are averaged over at least an interval to begin with.
+	re.add(start, 1000)
+	assert.EqualValues(t, 1000, re.rate(start.Add(time.Second/2)))
+	for i := 1; i < 20; i++ {
+		re.add(start.Add(time.Duration(i)*time.Second), 1000)
+	}
+	now := start.Add(20 * time.Second)
+	assert.InDelta(t, 1000, re.rate(now), 1)
+	// Samples fall out of the window.
+	assert.InDelta(t, 4000.0/9, re.rate(now.Add(5*time.Second)), 1)
+	assert.EqualValues(t, 0, re.rate(now.Add(time.Minute)))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/anacrolix/log"
 	"github.com/anacrolix/missinggo/v2"
@@ -19,8 +20,12 @@ type Reader interface {
 	io.Closer
 	missinggo.ReadContexter
 	// Configure the number of bytes ahead of a read that should also be prioritized in preparation
-	// for further reads.
+	// for further reads. Disables adaptive readahead.
 	SetReadahead(int64)
+	// Continuously adjust the readahead, within [min, max] bytes, from the observed rate of reads
+	// and the torrent's download rate. The aim is for a consumer reading at a steady rate, such as
+	// a media player, to not stall, without prioritizing pieces far ahead of the read position.
+	SetAdaptiveReadahead(min, max int64)
 	// Don't wait for pieces to complete and be verified. Read calls return as soon as they can when
 	// the underlying chunks become available.
 	SetResponsive()
@@ -41,6 +46,8 @@ type reader struct {
 	mu        sync.Locker
 	pos       int64
 	readahead int64
+	// Non-nil if the readahead is being adjusted from observed rates.
+	adaptive *adaptiveReadahead
 	// The cached piece range this reader wants downloaded. The zero value corresponds to nothing.
 	// We cache this so that changes can be detected, and bubbled up to the Torrent only as
 	// required.
@@ -63,6 +70,17 @@ func (r *reader) SetNonResponsive() {
 func (r *reader) SetReadahead(readahead int64) {
 	r.mu.Lock()
 	r.readahead = readahead
+	r.adaptive = nil
+	r.mu.Unlock()
+	r.t.cl.lock()
+	defer r.t.cl.unlock()
+	r.posChanged()
+}
+
+func (r *reader) SetAdaptiveReadahead(min, max int64) {
+	r.mu.Lock()
+	r.adaptive = newAdaptiveReadahead(min, max, time.Now(), r.t.stats.BytesReadUsefulData.Int64())
+	r.readahead = r.adaptive.readahead(r.pieceLength(), r.readahead)
 	r.mu.Unlock()
 	r.t.cl.lock()
 	defer r.t.cl.unlock()
@@ -149,6 +167,7 @@ func (r *reader) ReadContext(ctx context.Context, b []byte) (n int, err error) {
 
 	r.mu.Lock()
 	r.pos += int64(n)
+	r.updateAdaptiveReadahead(int64(n))
 	r.posChanged()
 	r.mu.Unlock()
 	if r.pos >= r.length {
@@ -243,6 +262,25 @@ func (r *reader) Close() error {
 	return nil
 }
 
+// Resamples rates and recalculates the readahead if adaptive readahead is enabled.
+func (r *reader) updateAdaptiveReadahead(read int64) {
+	if r.adaptive == nil {
+		return
+	}
+	if r.adaptive.update(time.Now(), read, r.t.stats.BytesReadUsefulData.Int64()) {
+		r.readahead = r.adaptive.readahead(r.pieceLength(), r.readahead)
+	}
+}
+
+// The torrent's piece length, or 0 if the info isn't available yet. Called with r.mu, which is the
+// Client lock, held.
+func (r *reader) pieceLength() int64 {
+	if !r.t.haveInfo() {
+		return 0
+	}
+	return r.t.info.PieceLength
+}
+
 func (r *reader) posChanged() {
 	to := r.piecesUncached()
 	from := r.pieces
@@ -0,0 +1,111 @@
+package torrent
+
+import (
+	"github.com/anacrolix/missinggo/bitmap"
+)
+
+// State for downloading a torrent strictly in piece order.
+type sequentialDownload struct {
+	// The number of incomplete pieces at the front of the download that are pending at once.
+	window int
+	// Only pieces that would be wanted due to file or piece priorities are downloaded.
+	wantedOnly bool
+	// The pieces currently in the window.
+	pieces bitmap.Bitmap
+	// All pieces before this are complete. It advances as the window does, and moves back if an
+	// earlier piece becomes incomplete, so the window is found without scanning completed pieces.
+	first pieceIndex
+}
+
+// Downloads pieces in ascending order. Only the first window incomplete pieces are pending at any
+// time, so they can still be requested from many peers at once, and the window advances as they
+// complete. If wantedFilesOnly, pieces are only downloaded if File.SetPriority, Piece.SetPriority
+// or DownloadPieces have made them wanted, otherwise the whole torrent is downloaded. Readers, and
+// pieces raised above normal priority, continue to be prioritized regardless of the window. This
+// can be called before the info is available.
+func (t *Torrent) SetSequentialDownload(window int, wantedFilesOnly bool) {
+	if window < 1 {
+		window = 1
+	}
+	t.cl.lock()
+	defer t.cl.unlock()
+	t.sequential = &sequentialDownload{
+		window:     window,
+		wantedOnly: wantedFilesOnly,
+	}
+	if !t.haveInfo() {
+		return
+	}
+	t.updateSequentialWindow()
+	t.updateAllPiecePriorities()
+}
+
+// Reverts to downloading pieces according to their priorities in no particular order.
+func (t *Torrent) DisableSequentialDownload() {
+	t.cl.lock()
+	defer t.cl.unlock()
+	if t.sequential == nil {
+		return
+	}
+	t.sequential = nil
+	if t.haveInfo() {
+		t.updateAllPiecePriorities()
+	}
+}
+
+// Whether the piece would be downloaded in sequential mode once the window reaches it.
+func (t *Torrent) sequentialWantsPiece(piece pieceIndex) bool {
+	if !t.sequential.wantedOnly {
+		return true
+	}
+	p := &t.pieces[piece]
+	if p.priority != PiecePriorityNone {
+		return true
+	}
+	for _, f := range p.files {
+		if f.prio != PiecePriorityNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Recalculates the pieces in the sequential download window, and updates the priorities of pieces
+// that entered or left it.
+func (t *Torrent) updateSequentialWindow() {
+	s := t.sequential
+	if s == nil || !t.haveInfo() {
+		return
+	}
+	for s.first < t.numPieces() && t.pieceComplete(s.first) {
+		s.first++
+	}
+	var window bitmap.Bitmap
+	for i, n := s.first, 0; i < t.numPieces() && n < s.window; i++ {
+		if !t.pieceComplete(i) && t.sequentialWantsPiece(i) {
+			window.Add(i)
+			n++
+		}
+	}
+	old := s.pieces
+	s.pieces = window
+	old.IterTyped(func(piece int) bool {
+		if !window.Contains(piece) {
+			t.updatePiecePriority(piece)
+		}
+		return true
+	})
+	window.IterTyped(func(piece int) bool {
+		if !old.Contains(piece) {
+			t.updatePiecePriority(piece)
+		}
+		return true
+	})
+}
+
+// Moves the window back if a piece before it is no longer complete.
+func (t *Torrent) sequentialPieceIncomplete(piece pieceIndex) {
+	if s := t.sequential; s != nil && piece < s.first {
+		s.first = piece
+	}
+}
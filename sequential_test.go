@@ -0,0 +1,69 @@
+package torrent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/missinggo/bitmap"
+	"github.com/anacrolix/torrent/internal/testutil"
+)
+
+// Adds a torrent of 10 pieces, none of which are complete.
+func addTenPieceTorrent(t *testing.T) *Torrent {
+	cl := newTestingClient(t, testingConfig(t))
+	mi := (&testutil.Torrent{
+		Name:  "ten",
+		Files: []testutil.File{{Data: strings.Repeat("x", 50)}},
+	}).Metainfo(5)
+	tor, err := cl.AddTorrent(mi)
+	require.NoError(t, err)
+	// Pieces aren't wanted while their completion is being checked.
+	tor.VerifyData()
+	return tor
+}
+
+func (t *Torrent) setTestPieceComplete(piece pieceIndex, complete bool) {
+	t.cl.lock()
+	defer t.cl.unlock()
+	t.completedPieces.Set(bitmap.BitIndex(piece), complete)
+	t.pieceCompletionChanged(piece)
+}
+
+func TestSequentialWindowAdvances(t *testing.T) {
+	tor := addTenPieceTorrent(t)
+	tor.SetSequentialDownload(2, false)
+	window := func() []int {
+		tor.cl.rLock()
+		defer tor.cl.rUnlock()
+		return tor.sequential.pieces.ToSortedSlice()
+	}
+	assert.Equal(t, []int{0, 1}, window())
+	assert.Equal(t, PiecePriorityNormal, tor.PieceState(1).Priority)
+	assert.Equal(t, PiecePriorityNone, tor.PieceState(2).Priority)
+	tor.setTestPieceComplete(0, true)
+	assert.Equal(t, []int{1, 2}, window())
+	assert.Equal(t, PiecePriorityNormal, tor.PieceState(2).Priority)
+	tor.setTestPieceComplete(2, true)
+	assert.Equal(t, []int{1, 3}, window())
+	// A piece behind the window becoming incomplete brings the window back.
+	tor.setTestPieceComplete(0, false)
+	assert.Equal(t, []int{0, 1}, window())
+	assert.Equal(t, PiecePriorityNone, tor.PieceState(3).Priority)
+}
+
+func TestSequentialWindowExplicitPriorities(t *testing.T) {
+	tor := addTenPieceTorrent(t)
+	tor.SetSequentialDownload(1, true)
+	assert.Equal(t, PiecePriorityNone, tor.PieceState(0).Priority)
+	tor.Piece(6).SetPriority(PiecePriorityNormal)
+	tor.Piece(8).SetPriority(PiecePriorityHigh)
+	assert.Equal(t, PiecePriorityNormal, tor.PieceState(6).Priority)
+	// Raised pieces don't wait for the window.
+	assert.Equal(t, PiecePriorityHigh, tor.PieceState(8).Priority)
+	tor.setTestPieceComplete(6, true)
+	assert.Equal(t, PiecePriorityHigh, tor.PieceState(8).Priority)
+	assert.Equal(t, PiecePriorityNone, tor.PieceState(7).Priority)
+}
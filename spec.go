@@ -18,6 +18,16 @@ type TorrentSpec struct {
 	// set.
 	ChunkSize int
 	Storage   storage.ClientImpl
+	// Peer addresses in host:port form to add to the torrent immediately.
+	PeerAddrs []string
+	// BEP 19 web seed URLs.
+	Webseeds []string
+	// Ranges of indexes into the info's files to download once the info is available (BEP 53).
+	// Indexes past the last file are ignored. If nil, no files are selected.
+	SelectedFiles []metainfo.FileIndexRange
+	// URLs where the metainfo can be fetched over HTTP, as an alternative to obtaining the info
+	// from peers.
+	Sources []string
 }
 
 func TorrentSpecFromMagnetURI(uri string) (spec *TorrentSpec, err error) {
@@ -26,9 +36,16 @@ func TorrentSpecFromMagnetURI(uri string) (spec *TorrentSpec, err error) {
 		return
 	}
 	spec = &TorrentSpec{
-		Trackers:    [][]string{m.Trackers},
-		DisplayName: m.DisplayName,
-		InfoHash:    m.InfoHash,
+		Trackers:      [][]string{m.Trackers},
+		DisplayName:   m.DisplayName,
+		InfoHash:      m.InfoHash,
+		PeerAddrs:     m.PeerAddrs,
+		Webseeds:      m.WebSeeds,
+		SelectedFiles: m.SelectOnly,
+		// Magnet "as" values usually link to the content itself rather than the metainfo, so
+		// they're not used as Sources. A single file can't be used as a web seed without the
+		// info either.
+		Sources: m.ExactSources,
 	}
 	return
 }
@@ -40,6 +57,7 @@ func TorrentSpecFromMetaInfo(mi *metainfo.MetaInfo) (spec *TorrentSpec) {
 		InfoBytes:   mi.InfoBytes,
 		DisplayName: info.Name,
 		InfoHash:    mi.HashInfoBytes(),
+		Webseeds:    mi.UrlList,
 	}
 	if spec.Trackers == nil && mi.Announce != "" {
 		spec.Trackers = [][]string{{mi.Announce}}
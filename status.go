@@ -0,0 +1,402 @@
+package torrent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anacrolix/missinggo/iter"
+	"github.com/anacrolix/missinggo/slices"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/dustin/go-humanize"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// A snapshot of Client state for diagnostics. It can be JSON encoded, and is what
+// Client.WriteStatus renders.
+type ClientStatus struct {
+	ListenPort  int
+	PeerID      PeerID
+	AnnounceKey int32
+	BannedIPs   int
+	Listeners   []ListenerStatus
+	DhtServers  []DhtServerStatus
+	Stats       ConnStats
+	// Bytes per second over all torrents. See Client.TransferRates.
+	DownloadRate float64
+	UploadRate   float64
+	// Ordered by infohash.
+	Torrents []TorrentStatus
+}
+
+type ListenerStatus struct {
+	Network string
+	Addr    string
+}
+
+type DhtServerStatus struct {
+	Network string
+	Addr    string
+	ID      [20]byte
+	// Whatever the DhtServer implementation provides.
+	Stats interface{}
+}
+
+// A snapshot of Torrent state for diagnostics.
+type TorrentStatus struct {
+	InfoHash metainfo.Hash
+	Name     string
+	// The remaining fields up to Trackers are only meaningful once the info is available.
+	HaveInfo     bool
+	Length       int64
+	BytesMissing int64
+	// Which metadata pieces have been received, while the info isn't available.
+	MetadataLength int
+	MetadataHave   []bool `json:",omitempty"`
+	PieceLength    int64
+	NumPieces      int
+	PiecesComplete int
+	PieceStateRuns []PieceStateRun
+	// Piece ranges wanted by Readers, end exclusive.
+	ReaderPieces [][2]int
+	Trackers     []TrackerStatus
+	DhtAnnounces int
+	Stats        TorrentStats
+	// Ordered best first.
+	Conns []ConnStatus
+}
+
+type TrackerStatus struct {
+	URL string
+	// Zero if there hasn't been an announce yet.
+	LastAnnounce time.Time
+	Interval     time.Duration
+	NumPeers     int
+	Err          string `json:",omitempty"`
+}
+
+// A snapshot of a peer connection for diagnostics.
+type ConnStatus struct {
+	PeerID         PeerID
+	ExtensionBytes string
+	LocalAddr      string
+	RemoteAddr     string
+	// Zero times mean the event hasn't occurred.
+	LastMessage   time.Time
+	Connected     time.Time
+	LastHelpful   time.Time
+	InterestTime  time.Duration
+	ExpectingTime time.Duration
+	// How many pieces the peer has, and the best guess at how many there are.
+	PeerPieces      int
+	PeerTotalPieces int
+	PiecesTouched   int
+	Stats           ConnStats
+	// Request counts. Requests are sent when LocalRequests drops to RequestsLowWater, up to
+	// NominalMaxRequests. PeerRequests are the requests received from the peer.
+	RequestsLowWater   int
+	LocalRequests      int
+	NominalMaxRequests int
+	PeerRequests       int
+	// Transmission style flags, and just the connection flags. See connection.statusFlags.
+	Flags           string
+	ConnectionFlags string
+	// Useful data and data sent in bytes per second, over the last several seconds.
+	DownloadRate float64
+	UploadRate   float64
+	NextPieces   []int
+	// Whether requests are made without the connection's piece inclination.
+	Fastest bool
+}
+
+// Returns a snapshot of the Client and all its torrents.
+func (cl *Client) Status() ClientStatus {
+	cl.rLock()
+	defer cl.rUnlock()
+	return cl.statusLocked()
+}
+
+func (cl *Client) statusLocked() (ret ClientStatus) {
+	ret = ClientStatus{
+		ListenPort:  cl.LocalPort(),
+		PeerID:      cl.PeerID(),
+		AnnounceKey: cl.announceKey(),
+		BannedIPs:   len(cl.badPeerIPsLocked()),
+		Stats:       cl.stats.Copy(),
+	}
+	ret.DownloadRate, ret.UploadRate = cl.rates.rates(time.Now())
+	for _, s := range cl.conns {
+		ret.Listeners = append(ret.Listeners, listenerStatus(s.Addr()))
+	}
+	cl.eachDhtServer(func(s DhtServer) {
+		ret.DhtServers = append(ret.DhtServers, DhtServerStatus{
+			Network: s.Addr().Network(),
+			Addr:    s.Addr().String(),
+			ID:      s.ID(),
+			Stats:   s.Stats(),
+		})
+	})
+	ts := cl.torrentsAsSlice()
+	sortTorrentsByInfoHash(ts)
+	for _, t := range ts {
+		ret.Torrents = append(ret.Torrents, t.statusLocked())
+	}
+	return
+}
+
+func listenerStatus(addr net.Addr) ListenerStatus {
+	return ListenerStatus{addr.Network(), addr.String()}
+}
+
+// Returns a snapshot of the torrent and its connections.
+func (t *Torrent) Status() TorrentStatus {
+	t.cl.rLock()
+	defer t.cl.rUnlock()
+	return t.statusLocked()
+}
+
+func (t *Torrent) statusLocked() (ret TorrentStatus) {
+	ret = TorrentStatus{
+		InfoHash:       t.infoHash,
+		Name:           t.name(),
+		HaveInfo:       t.haveInfo(),
+		MetadataLength: t.metadataSize(),
+		DhtAnnounces:   t.numDHTAnnounces,
+		Stats:          t.statsLocked(),
+	}
+	if ret.HaveInfo {
+		ret.Length = *t.length
+		ret.BytesMissing = t.bytesMissingLocked()
+		ret.PieceLength = int64(t.usualPieceSize())
+		ret.NumPieces = t.numPieces()
+		ret.PiecesComplete = t.numPiecesCompleted()
+		ret.PieceStateRuns = t.pieceStateRuns()
+	} else {
+		ret.MetadataHave = append([]bool(nil), t.metadataCompletedChunks...)
+	}
+	t.forReaderOffsetPieces(func(begin, end pieceIndex) (again bool) {
+		ret.ReaderPieces = append(ret.ReaderPieces, [2]int{begin, end})
+		return true
+	})
+	for _, ta := range slices.Sort(slices.FromMapElems(t.trackerAnnouncers), func(l, r *trackerScraper) bool {
+		return l.u.String() < r.u.String()
+	}).([]*trackerScraper) {
+		ret.Trackers = append(ret.Trackers, ta.status())
+	}
+	conns := t.connsAsSlice()
+	slices.Sort(conns, worseConn)
+	for _, c := range conns {
+		ret.Conns = append(ret.Conns, c.status())
+	}
+	return
+}
+
+func (cn *connection) status() ConnStatus {
+	ret := ConnStatus{
+		PeerID:             cn.PeerID,
+		ExtensionBytes:     cn.PeerExtensionBytes.String(),
+		RemoteAddr:         cn.remoteAddr.String(),
+		LastMessage:        cn.lastMessageReceived,
+		Connected:          cn.completedHandshake,
+		LastHelpful:        cn.lastHelpful(),
+		InterestTime:       cn.cumInterest(),
+		ExpectingTime:      cn.totalExpectingTime(),
+		PeerPieces:         cn.peerPieces.Len(),
+		PeerTotalPieces:    cn.bestPeerNumPieces(),
+		PiecesTouched:      len(cn.peerTouchedPieces),
+		Stats:              cn.stats.Copy(),
+		RequestsLowWater:   cn.requestsLowWater,
+		LocalRequests:      cn.numLocalRequests(),
+		NominalMaxRequests: cn.nominalMaxRequests(),
+		PeerRequests:       len(cn.PeerRequests),
+		Flags:              cn.statusFlags(),
+		ConnectionFlags:    cn.connectionFlags(),
+		Fastest:            cn.shouldRequestWithoutBias(),
+	}
+	if cn.peerSentHaveAll {
+		ret.PeerPieces = ret.PeerTotalPieces
+	}
+	ret.DownloadRate, ret.UploadRate = cn.rates.rates(time.Now())
+	if cn.conn != nil {
+		ret.LocalAddr = cn.localAddr().String()
+	}
+	for _, p := range iter.ToSlice(iter.Head(10, cn.iterPendingPiecesUntyped)) {
+		ret.NextPieces = append(ret.NextPieces, p.(int))
+	}
+	return ret
+}
+
+func (ts *trackerScraper) status() TrackerStatus {
+	ret := TrackerStatus{
+		URL:          ts.u.String(),
+		LastAnnounce: ts.lastAnnounce.Completed,
+		Interval:     ts.lastAnnounce.Interval,
+		NumPeers:     ts.lastAnnounce.NumPeers,
+	}
+	if ts.lastAnnounce.Err != nil {
+		ret.Err = ts.lastAnnounce.Err.Error()
+	}
+	return ret
+}
+
+// Writes the human readable form of the status.
+func (me *ClientStatus) Write(_w io.Writer) {
+	w := bufio.NewWriter(_w)
+	defer w.Flush()
+	fmt.Fprintf(w, "Listen port: %d\n", me.ListenPort)
+	fmt.Fprintf(w, "Peer ID: %+q\n", me.PeerID)
+	fmt.Fprintf(w, "Announce key: %x\n", me.AnnounceKey)
+	fmt.Fprintf(w, "Banned IPs: %d\n", me.BannedIPs)
+	for _, s := range me.DhtServers {
+		fmt.Fprintf(w, "%s DHT server at %s:\n", s.Network, s.Addr)
+		fmt.Fprintf(w, " ID: %x\n", s.ID)
+		spew.Fdump(w, s.Stats)
+	}
+	spew.Fdump(w, me.Stats)
+	fmt.Fprintf(w, "Rates: down %.1f KiB/s, up %.1f KiB/s\n", me.DownloadRate/(1<<10), me.UploadRate/(1<<10))
+	fmt.Fprintf(w, "# Torrents: %d\n", len(me.Torrents))
+	fmt.Fprintln(w)
+	for i := range me.Torrents {
+		ts := &me.Torrents[i]
+		if ts.Name == "" {
+			fmt.Fprint(w, "<unknown name>")
+		} else {
+			fmt.Fprint(w, ts.Name)
+		}
+		fmt.Fprint(w, "\n")
+		if ts.HaveInfo {
+			fmt.Fprintf(w, "%f%% of %d bytes (%s)", 100*(1-float64(ts.BytesMissing)/float64(ts.Length)), ts.Length, humanize.Bytes(uint64(ts.Length)))
+		} else {
+			w.WriteString("<missing metainfo>")
+		}
+		fmt.Fprint(w, "\n")
+		ts.Write(w)
+		fmt.Fprintln(w)
+	}
+}
+
+// Writes the human readable form of the status.
+func (me *TorrentStatus) Write(w io.Writer) {
+	fmt.Fprintf(w, "Infohash: %s\n", me.InfoHash.HexString())
+	fmt.Fprintf(w, "Metadata length: %d\n", me.MetadataLength)
+	if !me.HaveInfo {
+		fmt.Fprintf(w, "Metadata have: ")
+		for _, h := range me.MetadataHave {
+			fmt.Fprintf(w, "%c", func() rune {
+				if h {
+					return 'H'
+				} else {
+					return '.'
+				}
+			}())
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Piece length: %s\n", func() string {
+		if me.HaveInfo {
+			return fmt.Sprint(me.PieceLength)
+		} else {
+			return "?"
+		}
+	}())
+	if me.HaveInfo {
+		fmt.Fprintf(w, "Num Pieces: %d (%d completed)\n", me.NumPieces, me.PiecesComplete)
+		fmt.Fprint(w, "Piece States:")
+		for _, psr := range me.PieceStateRuns {
+			w.Write([]byte(" "))
+			w.Write([]byte(pieceStateRunStatusChars(psr)))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Reader Pieces:")
+	for _, r := range me.ReaderPieces {
+		fmt.Fprintf(w, " %d:%d", r[0], r[1])
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "Enabled trackers:\n")
+	func() {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "    URL\tNext announce\tLast announce\n")
+		for _, ts := range me.Trackers {
+			fmt.Fprintf(tw, "    %s\n", ts.line())
+		}
+		tw.Flush()
+	}()
+
+	fmt.Fprintf(w, "DHT Announces: %d\n", me.DhtAnnounces)
+
+	spew.Fdump(w, me.Stats)
+
+	for i := range me.Conns {
+		fmt.Fprintf(w, "%2d. ", i+1)
+		me.Conns[i].Write(w)
+	}
+}
+
+func (me *TrackerStatus) line() string {
+	return fmt.Sprintf("%q\t%s\t%s",
+		me.URL,
+		func() string {
+			na := time.Until(me.LastAnnounce.Add(me.Interval))
+			if na > 0 {
+				na /= time.Second
+				na *= time.Second
+				return na.String()
+			} else {
+				return "anytime"
+			}
+		}(),
+		func() string {
+			if me.Err != "" {
+				return me.Err
+			}
+			if me.LastAnnounce.IsZero() {
+				return "never"
+			}
+			return fmt.Sprintf("%d peers", me.NumPeers)
+		}(),
+	)
+}
+
+// Writes the human readable form of the status.
+func (me *ConnStatus) Write(w io.Writer) {
+	// \t isn't preserved in <pre> blocks?
+	fmt.Fprintf(w, "%+-55q %s %s-%s\n", me.PeerID, me.ExtensionBytes, me.LocalAddr, me.RemoteAddr)
+	fmt.Fprintf(w, "    last msg: %s, connected: %s, last helpful: %s, itime: %s, etime: %s\n",
+		eventAgeString(me.LastMessage),
+		eventAgeString(me.Connected),
+		eventAgeString(me.LastHelpful),
+		me.InterestTime,
+		me.ExpectingTime,
+	)
+	fmt.Fprintf(w,
+		"    %d/%d completed, %d pieces touched, good chunks: %v/%v-%v reqq: (%d,%d,%d]-%d, flags: %s, dr: %.1f KiB/s, ur: %.1f KiB/s\n",
+		me.PeerPieces,
+		me.PeerTotalPieces,
+		me.PiecesTouched,
+		&me.Stats.ChunksReadUseful,
+		&me.Stats.ChunksRead,
+		&me.Stats.ChunksWritten,
+		me.RequestsLowWater,
+		me.LocalRequests,
+		me.NominalMaxRequests,
+		me.PeerRequests,
+		me.Flags,
+		me.DownloadRate/(1<<10),
+		me.UploadRate/(1<<10),
+	)
+	fmt.Fprintf(w, "    next pieces: %v%s\n",
+		me.NextPieces,
+		func() string {
+			if me.Fastest {
+				return " (fastest)"
+			} else {
+				return ""
+			}
+		}())
+}
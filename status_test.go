@@ -0,0 +1,68 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/internal/testutil"
+)
+
+// Returns a leecher that has downloaded the greeting from a seeder, and is still connected to it.
+func testGreetingLeecher(t *testing.T) *Torrent {
+	_, leecher := testSeederLeecher(t, nil)
+	leecher.DownloadAll()
+	require.Eventually(t, func() bool { return leecher.BytesMissing() == 0 }, 5*time.Second, 10*time.Millisecond)
+	requireNumPeerConns(t, leecher, 1)
+	return leecher
+}
+
+func TestStatusJSON(t *testing.T) {
+	leecher := testGreetingLeecher(t)
+	cl := leecher.cl
+	b, err := json.Marshal(cl.Status())
+	require.NoError(t, err)
+	var status struct {
+		PeerID string
+		Stats  struct {
+			BytesReadUsefulData int64
+		}
+		Torrents []struct {
+			Name     string
+			HaveInfo bool
+			Conns    []struct {
+				PeerID string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(b, &status))
+	peerID := cl.PeerID()
+	assert.Equal(t, hex.EncodeToString(peerID[:]), status.PeerID)
+	// Chunks can be received more than once.
+	assert.GreaterOrEqual(t, status.Stats.BytesReadUsefulData, int64(len(testutil.GreetingFileContents)))
+	require.Len(t, status.Torrents, 1)
+	ts := status.Torrents[0]
+	assert.Equal(t, testutil.GreetingFileName, ts.Name)
+	assert.True(t, ts.HaveInfo)
+	require.Len(t, ts.Conns, 1)
+	assert.Len(t, ts.Conns[0].PeerID, 40)
+}
+
+func TestStatusWrite(t *testing.T) {
+	leecher := testGreetingLeecher(t)
+	status := leecher.cl.Status()
+	var buf bytes.Buffer
+	status.Write(&buf)
+	text := buf.String()
+	assert.Contains(t, text, fmt.Sprintf("Peer ID: %+q\n", status.PeerID))
+	assert.Contains(t, text, "# Torrents: 1\n")
+	assert.Contains(t, text, testutil.GreetingFileName+"\n100.000000% of 13 bytes")
+	assert.Contains(t, text, "Infohash: "+leecher.InfoHash().HexString())
+	assert.Contains(t, text, fmt.Sprintf("%+q", status.Torrents[0].Conns[0].PeerID))
+}
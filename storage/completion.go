@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Records which pieces have been verified, for storage implementations that don't track it in the
+// data itself.
+type PieceCompletionGetSetter interface {
+	Get(metainfo.PieceKey) (Completion, error)
+	Set(_ metainfo.PieceKey, complete bool) error
+}
+
+type PieceCompletion interface {
+	PieceCompletionGetSetter
+	Close() error
+}
+
+// Piece completion that's only kept in memory. Completion is unknown for pieces that weren't set,
+// so they're checked again in each new process.
+type mapPieceCompletion struct {
+	mu sync.Mutex
+	m  map[metainfo.PieceKey]bool
+}
+
+var _ PieceCompletion = (*mapPieceCompletion)(nil)
+
+func NewMapPieceCompletion() PieceCompletion {
+	return &mapPieceCompletion{m: make(map[metainfo.PieceKey]bool)}
+}
+
+func (me *mapPieceCompletion) Close() error {
+	return nil
+}
+
+func (me *mapPieceCompletion) Get(pk metainfo.PieceKey) (c Completion, err error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	c.Complete, c.Ok = me.m[pk]
+	return
+}
+
+func (me *mapPieceCompletion) Set(pk metainfo.PieceKey, b bool) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.m[pk] = b
+	return nil
+}
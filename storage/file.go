@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// File-based storage for torrents, laid out as the torrent's files would be by other clients.
+// Names are sanitized as per metainfo.Info.SanitizedFilePaths, and padding files aren't stored.
+type fileClientImpl struct {
+	baseDir string
+	pc      PieceCompletion
+}
+
+// Stores torrent data in files below baseDir. Piece completion is kept in memory.
+func NewFile(baseDir string) ClientImpl {
+	return NewFileWithCompletion(baseDir, NewMapPieceCompletion())
+}
+
+func NewFileWithCompletion(baseDir string, completion PieceCompletion) ClientImpl {
+	return &fileClientImpl{baseDir, completion}
+}
+
+func (me *fileClientImpl) Close() error {
+	return me.pc.Close()
+}
+
+func (me *fileClientImpl) OpenTorrent(info *metainfo.Info, infoHash metainfo.Hash) (TorrentImpl, error) {
+	t := &fileTorrentImpl{
+		infoHash:   infoHash,
+		completion: me.pc,
+	}
+	var offset int64
+	for i, p := range FilePaths(me.baseDir, info) {
+		fi := info.UpvertedFiles()[i]
+		t.files = append(t.files, file{
+			path:   p,
+			offset: offset,
+			length: fi.Length,
+		})
+		offset += fi.Length
+	}
+	return t, nil
+}
+
+// Returns the paths that file storage uses for each file in info.UpvertedFiles, below baseDir.
+// Padding files have empty paths, as they aren't stored.
+func FilePaths(baseDir string, info *metainfo.Info) (ret []string) {
+	name := info.SanitizedName()
+	for _, comps := range info.SanitizedFilePaths() {
+		switch {
+		case comps == nil:
+			ret = append(ret, "")
+		case info.IsDir():
+			ret = append(ret, filepath.Join(append([]string{baseDir, name}, comps...)...))
+		default:
+			ret = append(ret, filepath.Join(baseDir, name))
+		}
+	}
+	return
+}
+
+type file struct {
+	// Empty for padding files.
+	path   string
+	offset int64
+	length int64
+}
+
+type fileTorrentImpl struct {
+	files      []file
+	infoHash   metainfo.Hash
+	completion PieceCompletionGetSetter
+	handles    fileHandles
+}
+
+func (me *fileTorrentImpl) Piece(p metainfo.Piece) PieceImpl {
+	return &filePieceImpl{me, p}
+}
+
+func (me *fileTorrentImpl) Close() error {
+	me.handles.closeAll()
+	return nil
+}
+
+type filePieceImpl struct {
+	t *fileTorrentImpl
+	p metainfo.Piece
+}
+
+func (me *filePieceImpl) pieceKey() metainfo.PieceKey {
+	return metainfo.PieceKey{InfoHash: me.t.infoHash, Index: me.p.Index()}
+}
+
+func (me *filePieceImpl) Completion() Completion {
+	c, err := me.t.completion.Get(me.pieceKey())
+	if err != nil || !c.Ok {
+		return Completion{Ok: false}
+	}
+	if !c.Complete {
+		return c
+	}
+	// Check the data is still there.
+	begin := me.p.Offset()
+	end := begin + me.p.Length()
+	for _, f := range me.t.files {
+		if f.path == "" || f.offset+f.length <= begin || f.offset >= end {
+			continue
+		}
+		fi, err := os.Stat(f.path)
+		if err != nil || fi.Size() < f.length {
+			me.MarkNotComplete()
+			return Completion{Complete: false, Ok: true}
+		}
+	}
+	return c
+}
+
+// Also creates any empty files at the piece's offset, since there's never any data to write to
+// them.
+func (me *filePieceImpl) MarkComplete() error {
+	begin := me.p.Offset()
+	end := begin + me.p.Length()
+	last := me.p.Index() == me.p.Info.NumPieces()-1
+	for _, f := range me.t.files {
+		if f.path == "" || f.length != 0 || f.offset < begin || f.offset > end || f.offset == end && !last {
+			continue
+		}
+		h, err := me.t.handles.get(f.path, true)
+		if err != nil {
+			return err
+		}
+		me.t.handles.release(h)
+	}
+	return me.t.completion.Set(me.pieceKey(), true)
+}
+
+func (me *filePieceImpl) MarkNotComplete() error {
+	return me.t.completion.Set(me.pieceKey(), false)
+}
+
+// Calls f for each file overlapping len bytes at off in the piece, with the offset into the file
+// and the number of bytes, stopping at the first error.
+func (me *filePieceImpl) forFiles(off int64, n int, f func(file, int64, int) error) error {
+	off += me.p.Offset()
+	for _, fl := range me.t.files {
+		if n == 0 {
+			break
+		}
+		if fl.offset+fl.length <= off {
+			continue
+		}
+		n1 := n
+		if rem := fl.offset + fl.length - off; int64(n1) > rem {
+			n1 = int(rem)
+		}
+		if err := f(fl, off-fl.offset, n1); err != nil {
+			return err
+		}
+		off += int64(n1)
+		n -= n1
+	}
+	return nil
+}
+
+func (me *filePieceImpl) ReadAt(b []byte, off int64) (n int, err error) {
+	err = me.forFiles(off, len(b), func(f file, fileOff int64, n1 int) error {
+		if f.path == "" {
+			for i := range b[n : n+n1] {
+				b[n+i] = 0
+			}
+			n += n1
+			return nil
+		}
+		h, err := me.t.handles.get(f.path, false)
+		if err != nil {
+			return err
+		}
+		defer me.t.handles.release(h)
+		m, err := h.f.ReadAt(b[n:n+n1], fileOff)
+		n += m
+		if err == io.EOF && m < n1 {
+			return io.ErrUnexpectedEOF
+		}
+		if m == n1 {
+			return nil
+		}
+		return err
+	})
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return
+}
+
+func (me *filePieceImpl) WriteAt(b []byte, off int64) (n int, err error) {
+	err = me.forFiles(off, len(b), func(f file, fileOff int64, n1 int) error {
+		if f.path == "" {
+			n += n1
+			return nil
+		}
+		h, err := me.t.handles.get(f.path, true)
+		if err != nil {
+			return err
+		}
+		defer me.t.handles.release(h)
+		m, err := h.f.WriteAt(b[n:n+n1], fileOff)
+		n += m
+		return err
+	})
+	return
+}
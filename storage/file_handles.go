@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The most files kept open for each torrent.
+const maxOpenFilesPerTorrent = 32
+
+// Keeps recently used files open, so that each chunk read or written doesn't open its file again.
+type fileHandles struct {
+	mu sync.Mutex
+	// Of *fileHandle, with the most recently used at the front.
+	lru  list.List
+	open map[string]*list.Element
+}
+
+type fileHandle struct {
+	path     string
+	f        *os.File
+	writable bool
+	// Uses in progress. The file is closed when it has been evicted and isn't in use.
+	refs    int
+	evicted bool
+}
+
+// Returns an open handle for path, which must be released when done with. If write is set, the
+// file and its directory are created if necessary.
+func (me *fileHandles) get(path string, write bool) (*fileHandle, error) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if e, ok := me.open[path]; ok {
+		h := e.Value.(*fileHandle)
+		if h.writable || !write {
+			me.lru.MoveToFront(e)
+			h.refs++
+			return h, nil
+		}
+		me.evict(e)
+	}
+	f, writable, err := openFile(path, write)
+	if err != nil {
+		return nil, err
+	}
+	h := &fileHandle{path: path, f: f, writable: writable, refs: 1}
+	if me.open == nil {
+		me.open = make(map[string]*list.Element)
+	}
+	me.open[path] = me.lru.PushFront(h)
+	for me.lru.Len() > maxOpenFilesPerTorrent {
+		me.evict(me.lru.Back())
+	}
+	return h, nil
+}
+
+func (me *fileHandles) release(h *fileHandle) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	h.refs--
+	if h.evicted && h.refs == 0 {
+		h.f.Close()
+	}
+}
+
+func (me *fileHandles) evict(e *list.Element) {
+	h := me.lru.Remove(e).(*fileHandle)
+	delete(me.open, h.path)
+	h.evicted = true
+	if h.refs == 0 {
+		h.f.Close()
+	}
+}
+
+func (me *fileHandles) closeAll() {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	for me.lru.Len() != 0 {
+		me.evict(me.lru.Front())
+	}
+}
+
+// Opens files for writing where possible, so one handle does for both reads and writes. Existing
+// files that can't be written are opened read-only for reads.
+func openFile(path string, write bool) (f *os.File, writable bool, err error) {
+	if write {
+		if err = os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			return
+		}
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+		return f, true, err
+	}
+	f, err = os.OpenFile(path, os.O_RDWR, 0)
+	if err == nil {
+		return f, true, nil
+	}
+	if os.IsNotExist(err) {
+		return
+	}
+	f, err = os.Open(path)
+	return f, false, err
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestFileStoragePadding(t *testing.T) {
+	dir := t.TempDir()
+	info := metainfo.Info{
+		Name:        "t",
+		PieceLength: 4,
+		Pieces:      make([]byte, 2*metainfo.HashSize),
+		Files: []metainfo.FileInfo{
+			{Path: []string{"a"}, Length: 3},
+			{Path: []string{".pad", "1"}, Length: 1, Attr: "p"},
+			{Path: []string{"b"}, Length: 2},
+		},
+	}
+	ci := NewFile(dir)
+	defer ci.Close()
+	tor, err := NewClient(ci).OpenTorrent(&info, metainfo.Hash{})
+	require.NoError(t, err)
+	p := tor.Piece(info.Piece(0))
+	n, err := p.WriteAt([]byte("abcX"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	_, err = tor.Piece(info.Piece(1)).WriteAt([]byte("de"), 0)
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(dir, "t", "a"))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+	_, err = os.Stat(filepath.Join(dir, "t", ".pad"))
+	assert.True(t, os.IsNotExist(err))
+	b, err = io.ReadAll(io.NewSectionReader(p, 0, 4))
+	require.NoError(t, err)
+	assert.Equal(t, "abc\x00", string(b))
+	assert.False(t, p.Completion().Ok)
+	require.NoError(t, p.MarkComplete())
+	assert.Equal(t, Completion{Complete: true, Ok: true}, p.Completion())
+}
+
+func TestFileStorageEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	info := metainfo.Info{
+		Name:        "t",
+		PieceLength: 4,
+		Pieces:      make([]byte, 2*metainfo.HashSize),
+		Files: []metainfo.FileInfo{
+			{Path: []string{"empty0"}, Length: 0},
+			{Path: []string{"a"}, Length: 4},
+			{Path: []string{"empty1"}, Length: 0},
+			{Path: []string{"b"}, Length: 2},
+			{Path: []string{"empty2"}, Length: 0},
+		},
+	}
+	ci := NewFile(dir)
+	defer ci.Close()
+	tor, err := NewClient(ci).OpenTorrent(&info, metainfo.Hash{})
+	require.NoError(t, err)
+	defer tor.Close()
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, "t", name))
+		return err == nil
+	}
+	require.NoError(t, tor.Piece(info.Piece(0)).MarkComplete())
+	assert.True(t, exists("empty0"))
+	// Files at a piece boundary belong to the following piece.
+	assert.False(t, exists("empty1"))
+	require.NoError(t, tor.Piece(info.Piece(1)).MarkComplete())
+	assert.True(t, exists("empty1"))
+	assert.True(t, exists("empty2"))
+}
+
+// Handles are reused, and evicted ones are closed once they're no longer in use.
+func TestFileHandles(t *testing.T) {
+	dir := t.TempDir()
+	var fh fileHandles
+	defer fh.closeAll()
+	path := func(i int) string {
+		return filepath.Join(dir, "d", strconv.Itoa(i))
+	}
+	_, err := fh.get(path(0), false)
+	assert.True(t, os.IsNotExist(err))
+	first, err := fh.get(path(0), true)
+	require.NoError(t, err)
+	h, err := fh.get(path(0), false)
+	require.NoError(t, err)
+	assert.Same(t, first, h)
+	fh.release(h)
+	for i := 1; i <= maxOpenFilesPerTorrent; i++ {
+		h, err := fh.get(path(i), true)
+		require.NoError(t, err)
+		fh.release(h)
+	}
+	assert.Equal(t, maxOpenFilesPerTorrent, fh.lru.Len())
+	// Evicted, but still usable until released.
+	assert.True(t, first.evicted)
+	_, err = first.f.WriteAt([]byte("x"), 0)
+	assert.NoError(t, err)
+	fh.release(first)
+	_, err = first.f.WriteAt([]byte("x"), 0)
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
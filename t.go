@@ -159,11 +159,41 @@ func (t *Torrent) DownloadPieces(begin, end pieceIndex) {
 }
 
 func (t *Torrent) downloadPiecesLocked(begin, end pieceIndex) {
+	t.raisePiecesPriority(begin, end, PiecePriorityNormal)
+}
+
+// Raises the priority of pieces in the range [begin, end) to at least prio, as per
+// Piece.SetPriority.
+func (t *Torrent) raisePiecesPriority(begin, end pieceIndex, prio piecePriority) {
 	for i := begin; i < end; i++ {
-		if t.pieces[i].priority.Raise(PiecePriorityNormal) {
+		if t.pieces[i].priority.Raise(prio) {
 			t.updatePiecePriority(i)
 		}
 	}
+	t.updateSequentialWindow()
+}
+
+// Prioritizes the edges of files matching fep, as per File.PrioritizeEdges. If the info isn't
+// available yet, this is applied when it arrives.
+func (t *Torrent) SetFileEdgePriorities(fep FileEdgePriorities) {
+	t.cl.lock()
+	defer t.cl.unlock()
+	t.fileEdgePriorities = &fep
+	if t.haveInfo() {
+		t.applyFileEdgePriorities()
+	}
+}
+
+func (t *Torrent) applyFileEdgePriorities() {
+	fep := t.fileEdgePriorities
+	if fep == nil {
+		return
+	}
+	for _, f := range *t.files {
+		if fep.matches(f) {
+			f.prioritizeEdges(fep.HeadBytes, fep.TailBytes)
+		}
+	}
 }
 
 func (t *Torrent) CancelPieces(begin, end pieceIndex) {
@@ -181,12 +211,22 @@ func (t *Torrent) cancelPiecesLocked(begin, end pieceIndex) {
 		p.priority = PiecePriorityNone
 		t.updatePiecePriority(i)
 	}
+	t.updateSequentialWindow()
 }
 
 func (t *Torrent) initFiles() {
 	var offset int64
 	t.files = new([]*File)
-	for _, fi := range t.info.UpvertedFiles() {
+	safePaths := t.info.SanitizedFilePaths()
+	for i, fi := range t.info.UpvertedFiles() {
+		if fi.IsPad() {
+			offset += fi.Length
+			continue
+		}
+		safePath := safePaths[i]
+		if t.info.IsDir() {
+			safePath = append([]string{t.info.SanitizedName()}, safePath...)
+		}
 		*t.files = append(*t.files, &File{
 			t,
 			strings.Join(append([]string{t.info.BestName()}, fi.BestPath()...), "/"),
@@ -195,13 +235,14 @@ func (t *Torrent) initFiles() {
 			fi,
 			fi.DisplayPath(t.info),
 			PiecePriorityNone,
+			strings.Join(safePath, "/"),
 		})
 		offset += fi.Length
 	}
 }
 
-// Returns handles to the files in the torrent. This requires that the Info is
-// available first.
+// Returns handles to the files in the torrent, excluding BEP 47 padding files. This requires that
+// the Info is available first.
 func (t *Torrent) Files() []*File {
 	return *t.files
 }
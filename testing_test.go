@@ -0,0 +1,71 @@
+package torrent
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent/internal/testutil"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// A config for Clients that only talk to each other over loopback TCP.
+func testingConfig(t testing.TB) *ClientConfig {
+	cfg := NewDefaultClientConfig()
+	cfg.ListenHost = func(string) string { return "127.0.0.1" }
+	cfg.ListenPort = 0
+	cfg.DataDir = t.TempDir()
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.DisableIPv6 = true
+	cfg.DisableUTP = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.Seed = true
+	// Every test peer has a loopback address.
+	cfg.DisableAcceptRateLimiting = true
+	return cfg
+}
+
+// Uses file storage in cfg.DataDir, if there's no DefaultStorage.
+func newTestingClient(t testing.TB, cfg *ClientConfig) *Client {
+	if cfg.DefaultStorage == nil {
+		cfg.DefaultStorage = storage.NewFile(cfg.DataDir)
+		t.Cleanup(func() { cfg.DefaultStorage.Close() })
+	}
+	cl, err := NewClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cl.Close() })
+	return cl
+}
+
+// Returns a Torrent seeding the greeting in its own Client.
+func testGreetingSeeder(t *testing.T) *Torrent {
+	dir, mi := testutil.GreetingTestTorrent()
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg := testingConfig(t)
+	cfg.DataDir = dir
+	seeder, err := newTestingClient(t, cfg).AddTorrent(mi)
+	require.NoError(t, err)
+	seeder.VerifyData()
+	return seeder
+}
+
+// Returns a seeder of the greeting, and a Torrent for it in another Client with the seeder added as
+// a peer. configureLeecher may be nil.
+func testSeederLeecher(t *testing.T, configureLeecher func(*ClientConfig)) (seeder, leecher *Torrent) {
+	seeder = testGreetingSeeder(t)
+	cfg := testingConfig(t)
+	if configureLeecher != nil {
+		configureLeecher(cfg)
+	}
+	leecher, err := newTestingClient(t, cfg).AddTorrent(testutil.GreetingMetaInfo())
+	require.NoError(t, err)
+	leecher.AddClientPeer(seeder.cl)
+	return
+}
+
+func requireNumPeerConns(t *testing.T, tor *Torrent, n int) {
+	require.Eventually(t, func() bool { return len(tor.PeerConns()) == n }, 5*time.Second, 10*time.Millisecond)
+}
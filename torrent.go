@@ -11,7 +11,6 @@ import (
 	"net/url"
 	"os"
 	"sync"
-	"text/tabwriter"
 	"time"
 	"unsafe"
 
@@ -28,7 +27,6 @@ import (
 	pp "github.com/anacrolix/torrent/peer_protocol"
 	"github.com/anacrolix/torrent/storage"
 	"github.com/anacrolix/torrent/tracker"
-	"github.com/davecgh/go-spew/spew"
 )
 
 func (t *Torrent) chunkIndexSpec(chunkIndex pp.Integer, piece pieceIndex) chunkSpec {
@@ -40,6 +38,7 @@ type Torrent struct {
 	// Torrent-level aggregate statistics. First in struct to ensure 64-bit
 	// alignment. See #262.
 	stats  ConnStats
+	rates  transferRates
 	cl     *Client
 	logger log.Logger
 
@@ -119,6 +118,15 @@ type Torrent struct {
 	// Set when .Info is obtained.
 	gotMetainfo missinggo.Event
 
+	// Non-nil if pieces are being downloaded in order. See SetSequentialDownload.
+	sequential *sequentialDownload
+	// Applied to files when the info becomes available.
+	fileEdgePriorities *FileEdgePriorities
+	// BEP 47 padding file data, ordered by offset.
+	padRegions []padRegion
+	// BEP 53 file index ranges to download when the info becomes available.
+	selectedFiles []metainfo.FileIndexRange
+
 	readers               map[*reader]struct{}
 	readerNowPieces       bitmap.Bitmap
 	readerReadaheadPieces bitmap.Bitmap
@@ -193,6 +201,11 @@ func (t *Torrent) setChunkSize(size pp.Integer) {
 			return &b
 		},
 	}
+	// Dirty chunks are indexed by chunk size, so the padding chunks have to be found again. Other
+	// progress on each piece is left alone.
+	for i := range t.pieces {
+		t.dirtyPadChunks(pieceIndex(i))
+	}
 }
 
 func (t *Torrent) pieceComplete(piece pieceIndex) bool {
@@ -229,22 +242,22 @@ func (t *Torrent) unclosedConnsAsSlice() (ret []*connection) {
 
 func (t *Torrent) addPeer(p Peer) {
 	cl := t.cl
-	peersAddedBySource.Add(string(p.Source), 1)
+	t.cl.metrics.peersAddedBySource.Add(string(p.Source), 1)
 	if t.closed.IsSet() {
 		return
 	}
 	if cl.badPeerIPPort(p.IP, p.Port) {
-		torrent.Add("peers not added because of bad addr", 1)
+		t.cl.metrics.torrent.Add("peers not added because of bad addr", 1)
 		return
 	}
 	if t.peers.Add(p) {
-		torrent.Add("peers replaced", 1)
+		t.cl.metrics.torrent.Add("peers replaced", 1)
 	}
 	t.openNewConns()
 	for t.peers.Len() > cl.config.TorrentPeersHighWater {
 		_, ok := t.peers.DeleteMin()
 		if ok {
-			torrent.Add("excess reserve peers discarded", 1)
+			t.cl.metrics.torrent.Add("excess reserve peers discarded", 1)
 		}
 	}
 }
@@ -306,6 +319,7 @@ func (t *Torrent) makePieces() {
 		beginFile := pieceFirstFileIndex(piece.torrentBeginOffset(), files)
 		endFile := pieceEndFileIndex(piece.torrentEndOffset(), files)
 		piece.files = files[beginFile:endFile]
+		t.dirtyPadChunks(pieceIndex(i))
 	}
 }
 
@@ -317,18 +331,18 @@ func pieceFirstFileIndex(pieceOffset int64, files []*File) int {
 			return i
 		}
 	}
-	return 0
+	return len(files)
 }
 
 // Returns the index after the last file containing the piece. files must be
-// ordered by offset.
+// ordered by offset, and may have gaps where padding files were omitted.
 func pieceEndFileIndex(pieceEndOffset int64, files []*File) int {
 	for i, f := range files {
-		if f.offset+f.length >= pieceEndOffset {
-			return i + 1
+		if f.offset >= pieceEndOffset {
+			return i
 		}
 	}
-	return 0
+	return len(files)
 }
 
 func (t *Torrent) cacheLength() {
@@ -340,8 +354,8 @@ func (t *Torrent) cacheLength() {
 }
 
 func (t *Torrent) setInfo(info *metainfo.Info) error {
-	if err := validateInfo(info); err != nil {
-		return fmt.Errorf("bad info: %s", err)
+	if err := info.Validate(); err != nil {
+		return fmt.Errorf("bad info: %w", err)
 	}
 	if t.storageOpener != nil {
 		var err error
@@ -356,6 +370,7 @@ func (t *Torrent) setInfo(info *metainfo.Info) error {
 	t.displayName = "" // Save a few bytes lol.
 	t.initFiles()
 	t.cacheLength()
+	t.initPadRegions()
 	t.makePieces()
 	return nil
 }
@@ -375,11 +390,15 @@ func (t *Torrent) onSetInfo() {
 			t.queuePieceCheck(pieceIndex(i))
 		}
 	}
+	t.updateSequentialWindow()
+	t.applyFileEdgePriorities()
+	t.applySelectedFiles()
 	t.cl.event.Broadcast()
 	t.gotMetainfo.Set()
 	t.updateWantPeersEvent()
 	t.pendingRequests = make(map[request]int)
 	t.lastRequested = make(map[request]*time.Timer)
+	t.publishEvent(Event{Type: EventMetadataReceived})
 }
 
 // Called when metadata for a torrent becomes available.
@@ -531,70 +550,6 @@ func pieceStateRunStatusChars(psr PieceStateRun) (ret string) {
 	return
 }
 
-func (t *Torrent) writeStatus(w io.Writer) {
-	fmt.Fprintf(w, "Infohash: %s\n", t.infoHash.HexString())
-	fmt.Fprintf(w, "Metadata length: %d\n", t.metadataSize())
-	if !t.haveInfo() {
-		fmt.Fprintf(w, "Metadata have: ")
-		for _, h := range t.metadataCompletedChunks {
-			fmt.Fprintf(w, "%c", func() rune {
-				if h {
-					return 'H'
-				} else {
-					return '.'
-				}
-			}())
-		}
-		fmt.Fprintln(w)
-	}
-	fmt.Fprintf(w, "Piece length: %s\n", func() string {
-		if t.haveInfo() {
-			return fmt.Sprint(t.usualPieceSize())
-		} else {
-			return "?"
-		}
-	}())
-	if t.info != nil {
-		fmt.Fprintf(w, "Num Pieces: %d (%d completed)\n", t.numPieces(), t.numPiecesCompleted())
-		fmt.Fprint(w, "Piece States:")
-		for _, psr := range t.pieceStateRuns() {
-			w.Write([]byte(" "))
-			w.Write([]byte(pieceStateRunStatusChars(psr)))
-		}
-		fmt.Fprintln(w)
-	}
-	fmt.Fprintf(w, "Reader Pieces:")
-	t.forReaderOffsetPieces(func(begin, end pieceIndex) (again bool) {
-		fmt.Fprintf(w, " %d:%d", begin, end)
-		return true
-	})
-	fmt.Fprintln(w)
-
-	fmt.Fprintf(w, "Enabled trackers:\n")
-	func() {
-		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
-		fmt.Fprintf(tw, "    URL\tNext announce\tLast announce\n")
-		for _, ta := range slices.Sort(slices.FromMapElems(t.trackerAnnouncers), func(l, r *trackerScraper) bool {
-			return l.u.String() < r.u.String()
-		}).([]*trackerScraper) {
-			fmt.Fprintf(tw, "    %s\n", ta.statusLine())
-		}
-		tw.Flush()
-	}()
-
-	fmt.Fprintf(w, "DHT Announces: %d\n", t.numDHTAnnounces)
-
-	spew.NewDefaultConfig()
-	spew.Fdump(w, t.statsLocked())
-
-	conns := t.connsAsSlice()
-	slices.Sort(conns, worseConn)
-	for i, c := range conns {
-		fmt.Fprintf(w, "%2d. ", i+1)
-		c.WriteStatus(w, t)
-	}
-}
-
 func (t *Torrent) haveInfo() bool {
 	return t.info != nil
 }
@@ -607,6 +562,7 @@ func (t *Torrent) newMetaInfo() metainfo.MetaInfo {
 		Comment:      "dynamic metainfo from client",
 		CreatedBy:    "go.torrent",
 		AnnounceList: t.metainfo.UpvertedAnnounceList(),
+		UrlList:      t.metainfo.UrlList,
 		InfoBytes: func() []byte {
 			if t.haveInfo() {
 				return t.metadataBytes
@@ -718,6 +674,7 @@ func (t *Torrent) pieceNumChunks(piece pieceIndex) pp.Integer {
 
 func (t *Torrent) pendAllChunkSpecs(pieceIndex pieceIndex) {
 	t.pieces[pieceIndex].dirtyChunks.Clear()
+	t.dirtyPadChunks(pieceIndex)
 }
 
 func (t *Torrent) pieceLength(piece pieceIndex) pp.Integer {
@@ -740,13 +697,14 @@ func (t *Torrent) hashPiece(piece pieceIndex) (ret metainfo.Hash) {
 	p.waitNoPendingWrites()
 	ip := t.info.Piece(int(piece))
 	pl := ip.Length()
-	n, err := io.Copy(hash, io.NewSectionReader(t.pieces[piece].Storage(), 0, pl))
+	n, err := io.Copy(hash, io.NewSectionReader(p.readerAt(), 0, pl))
 	if n == pl {
 		missinggo.CopyExact(&ret, hash.Sum(nil))
 		return
 	}
 	if err != io.ErrUnexpectedEOF && !os.IsNotExist(err) {
 		t.logger.Printf("unexpected error hashing piece with %T: %s", t.storage.TorrentImpl, err)
+		t.publishEvent(Event{Type: EventStorageError, Piece: piece, Err: err})
 	}
 	return
 }
@@ -1004,13 +962,15 @@ func (t *Torrent) pieceCompletionChanged(piece pieceIndex) {
 		t.onPieceCompleted(piece)
 	} else {
 		t.onIncompletePiece(piece)
+		t.sequentialPieceIncomplete(piece)
 	}
+	t.updateSequentialWindow()
 	t.updatePiecePriority(piece)
 }
 
 func (t *Torrent) numReceivedConns() (ret int) {
 	for c := range t.conns {
-		if c.Discovery == peerSourceIncoming {
+		if c.Discovery == PeerSourceIncoming {
 			ret++
 		}
 	}
@@ -1047,16 +1007,16 @@ func (t *Torrent) openNewConns() {
 func (t *Torrent) getConnPieceInclination() []int {
 	_ret := t.connPieceInclinationPool.Get()
 	if _ret == nil {
-		pieceInclinationsNew.Add(1)
+		t.cl.metrics.pieceInclinationsNew.Add(1)
 		return rand.Perm(int(t.numPieces()))
 	}
-	pieceInclinationsReused.Add(1)
+	t.cl.metrics.pieceInclinationsReused.Add(1)
 	return *_ret.(*[]int)
 }
 
 func (t *Torrent) putPieceInclination(pi []int) {
 	t.connPieceInclinationPool.Put(&pi)
-	pieceInclinationsPut.Add(1)
+	t.cl.metrics.pieceInclinationsPut.Add(1)
 }
 
 func (t *Torrent) updatePieceCompletion(piece pieceIndex) bool {
@@ -1082,7 +1042,7 @@ func (t *Torrent) updatePieceCompletion(piece pieceIndex) bool {
 func (t *Torrent) readAt(b []byte, off int64) (n int, err error) {
 	p := &t.pieces[off/t.info.PieceLength]
 	p.waitNoPendingWrites()
-	return p.Storage().ReadAt(b, off-p.Info().Offset())
+	return p.readerAt().ReadAt(b, off-p.Info().Offset())
 }
 
 func (t *Torrent) updateAllPieceCompletions() {
@@ -1189,7 +1149,10 @@ func (t *Torrent) deleteConnection(c *connection) (ret bool) {
 	}
 	_, ret = t.conns[c]
 	delete(t.conns, c)
-	torrent.Add("deleted connections", 1)
+	if ret {
+		t.publishConnEvent(EventPeerDisconnected, c)
+	}
+	t.cl.metrics.torrent.Add("deleted connections", 1)
 	c.deleteAllRequests()
 	if len(t.conns) == 0 {
 		t.assertNoPendingRequests()
@@ -1340,7 +1303,7 @@ func (t *Torrent) consumeDhtAnnouncePeers(pvs <-chan dht.PeersValues) {
 			t.addPeer(Peer{
 				IP:     cp.IP[:],
 				Port:   cp.Port,
-				Source: peerSourceDHTGetPeers,
+				Source: PeerSourceDHTGetPeers,
 			})
 		}
 		cl.unlock()
@@ -1419,6 +1382,7 @@ func (t *Torrent) statsLocked() (ret TorrentStats) {
 		}
 	}
 	ret.ConnStats = t.stats.Copy()
+	ret.DownloadRate, ret.UploadRate = t.rates.rates(time.Now())
 	return
 }
 
@@ -1463,7 +1427,7 @@ func (t *Torrent) reconcileHandshakeStats(c *connection) {
 func (t *Torrent) addConnection(c *connection) (err error) {
 	defer func() {
 		if err == nil {
-			torrent.Add("added connections", 1)
+			t.cl.metrics.torrent.Add("added connections", 1)
 		}
 	}()
 	if t.closed.IsSet() {
@@ -1495,6 +1459,7 @@ func (t *Torrent) addConnection(c *connection) (err error) {
 		panic(len(t.conns))
 	}
 	t.conns[c] = struct{}{}
+	t.publishConnEvent(EventPeerConnected, c)
 	return nil
 }
 
@@ -1537,13 +1502,14 @@ func (t *Torrent) pieceHashed(piece pieceIndex, correct bool) {
 	if p.storageCompletionOk {
 		// Don't score the first time a piece is hashed, it could be an initial check.
 		if correct {
-			pieceHashedCorrect.Add(1)
+			t.cl.metrics.pieceHashedCorrect.Add(1)
 		} else {
 			log.Fmsg("piece %d failed hash: %d connections contributed", piece, len(touchers)).AddValues(t, p).LogLevel(log.Debug, t.logger)
-			pieceHashedNotCorrect.Add(1)
+			t.cl.metrics.pieceHashedNotCorrect.Add(1)
 		}
 	}
 	if correct {
+		t.publishEvent(Event{Type: EventPieceVerified, Piece: piece})
 		if len(touchers) != 0 {
 			// Don't increment stats above connection-level for every involved connection.
 			t.allStats((*ConnStats).incrementPiecesDirtiedGood)
@@ -1554,8 +1520,10 @@ func (t *Torrent) pieceHashed(piece pieceIndex, correct bool) {
 		err := p.Storage().MarkComplete()
 		if err != nil {
 			t.logger.WithDefaultLevel(log.Warning).Printf("%T: error marking piece complete %d: %s", t.storage, piece, err)
+			t.publishEvent(Event{Type: EventStorageError, Piece: piece, Err: err})
 		}
 	} else {
+		t.publishEvent(Event{Type: EventPieceFailed, Piece: piece})
 		if len(touchers) != 0 {
 			// Don't increment stats above connection-level for every involved connection.
 			t.allStats((*ConnStats).incrementPiecesDirtiedBad)
@@ -1579,7 +1547,9 @@ func (t *Torrent) pieceHashed(piece pieceIndex, correct bool) {
 		t.onIncompletePiece(piece)
 		p.Storage().MarkNotComplete()
 	}
+	p.completingDownload = correct && len(touchers) != 0
 	t.updatePieceCompletion(piece)
+	p.completingDownload = false
 }
 
 func (t *Torrent) cancelRequestsForPiece(piece pieceIndex) {
@@ -1595,6 +1565,21 @@ func (t *Torrent) onPieceCompleted(piece pieceIndex) {
 	for conn := range t.conns {
 		conn.Have(piece)
 	}
+	files, all := t.completedByPiece(piece)
+	t.publishCompletionEvents(files, all)
+	if t.pieces[piece].completingDownload {
+		t.queueCompletionHooks(files, all)
+	}
+}
+
+// Returns the files that the piece completed, and whether it completed the torrent.
+func (t *Torrent) completedByPiece(piece pieceIndex) (files []*File, all bool) {
+	for _, f := range t.pieces[piece].files {
+		if t.fileComplete(f) {
+			files = append(files, f)
+		}
+	}
+	return files, t.haveAllPieces()
 }
 
 // Called when a piece is found to be not complete.
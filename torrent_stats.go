@@ -11,4 +11,8 @@ type TorrentStats struct {
 	ActivePeers      int
 	ConnectedSeeders int
 	HalfOpenPeers    int
+
+	// Useful data received and data sent in bytes per second, over the last several seconds.
+	DownloadRate float64
+	UploadRate   float64
 }
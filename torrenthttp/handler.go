@@ -0,0 +1,97 @@
+// Package torrenthttp serves the files of torrents over HTTP, fetching data from the swarm on
+// demand.
+package torrenthttp
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Serves the files of a Torrent by their path. Requests support Range and If-Range, and the ETag
+// is derived from the infohash, since torrent data is immutable. Each request gets its own Reader,
+// which is closed when the request completes or the client goes away, withdrawing the interest in
+// any pieces it was waiting for.
+type Handler struct {
+	Torrent *torrent.Torrent
+	// Bytes ahead of the read position to prioritize. If zero, the readahead adapts to the rate
+	// the client consumes the response, between MinReadahead and MaxReadahead.
+	Readahead                  int64
+	MinReadahead, MaxReadahead int64
+	// Return data as soon as it's available, without waiting for piece verification.
+	Responsive bool
+}
+
+var _ http.Handler = Handler{}
+
+const (
+	defaultMinReadahead = 1 << 20
+	defaultMaxReadahead = 64 << 20
+)
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-h.Torrent.GotInfo():
+	case <-r.Context().Done():
+		return
+	case <-h.Torrent.Closed():
+		http.Error(w, "torrent closed", http.StatusServiceUnavailable)
+		return
+	}
+	index, f := h.file(r.URL.Path)
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, h.Torrent.InfoHash().HexString(), index))
+	w.Header().Set("Content-Type", contentType(f.DisplayPath()))
+	// The Reader isn't created until data is read, as http.ServeContent seeks to find the size
+	// and range offsets, and HEAD requests don't read at all.
+	rs := f.NewReadSeeker(r.Context(), h.configureReader)
+	defer rs.Close()
+	http.ServeContent(w, r, f.DisplayPath(), time.Time{}, rs)
+}
+
+func (h Handler) configureReader(r torrent.Reader) {
+	if h.Responsive {
+		r.SetResponsive()
+	}
+	if h.Readahead != 0 {
+		r.SetReadahead(h.Readahead)
+		return
+	}
+	min, max := h.MinReadahead, h.MaxReadahead
+	if min == 0 {
+		min = defaultMinReadahead
+	}
+	if max == 0 {
+		max = defaultMaxReadahead
+	}
+	r.SetAdaptiveReadahead(min, max)
+}
+
+// Finds the file for the request path. The path can be relative to the torrent's root, as given by
+// File.DisplayPath, or include the torrent name, as given by File.Path and File.SafePath.
+func (h Handler) file(urlPath string) (int, *torrent.File) {
+	p := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	for i, f := range h.Torrent.Files() {
+		if f.DisplayPath() == p || f.Path() == p || f.SafePath() == p {
+			return i, f
+		}
+	}
+	return -1, nil
+}
+
+// The Content-Type is determined from the extension. Sniffing the content would force the start of
+// the file to be downloaded even if the client only wanted a later range.
+func contentType(name string) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,108 @@
+package torrenthttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/internal/testutil"
+	"github.com/anacrolix/torrent/storage"
+)
+
+func testTorrent(t *testing.T, dataDir string) *torrent.Torrent {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = dataDir
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.DefaultStorage = storage.NewFile(cfg.DataDir)
+	t.Cleanup(func() { cfg.DefaultStorage.Close() })
+	cl, err := torrent.NewClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cl.Close() })
+	tt, err := cl.AddTorrent(testutil.GreetingMetaInfo())
+	require.NoError(t, err)
+	return tt
+}
+
+func TestHandlerRanges(t *testing.T) {
+	dir, _ := testutil.GreetingTestTorrent()
+	defer os.RemoveAll(dir)
+	tt := testTorrent(t, dir)
+	tt.VerifyData()
+	srv := httptest.NewServer(Handler{Torrent: tt})
+	defer srv.Close()
+
+	get := func(header ...string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", srv.URL+"/greeting", nil)
+		require.NoError(t, err)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var b []byte
+		b, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(b)
+	}
+
+	resp, body := get()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, testutil.GreetingFileContents, body)
+	etag := resp.Header.Get("ETag")
+	assert.Contains(t, etag, tt.InfoHash().HexString())
+
+	resp, body = get("Range", "bytes=7-")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "world\n", body)
+
+	resp, _ = get("If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = get("Range", "bytes=0-4", "If-Range", etag)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+	// A stale validator gets the whole file.
+	resp, body = get("Range", "bytes=0-4", "If-Range", `"stale"`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, testutil.GreetingFileContents, body)
+
+	resp, _ = get()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = http.Get(srv.URL + "/missing")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// A request for data that isn't available blocks until the client goes away, and then releases its
+// Reader.
+func TestHandlerClientGoesAway(t *testing.T) {
+	tt := testTorrent(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/greeting", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Handler{Torrent: tt}.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	require.Eventually(t, func() bool {
+		return len(tt.Status().ReaderPieces) != 0
+	}, time.Second, time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler didn't return")
+	}
+	assert.Empty(t, tt.Status().ReaderPieces)
+}
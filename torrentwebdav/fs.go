@@ -0,0 +1,357 @@
+// Package torrentwebdav provides a read-only WebDAV view of the torrents in a Client. It's an
+// alternative to mounting torrents with FUSE where that isn't available.
+package torrentwebdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/net/webdav"
+)
+
+// Implements webdav.FileSystem over the torrents in a Client. Torrents appear once their info is
+// available, at the paths given by torrent.File.SafePath. Where those paths would collide with
+// another torrent's, the torrent's files are placed in a directory named by its infohash instead.
+// Directories are derived from the paths. File data is read through torrent.Reader, so it's fetched
+// on demand.
+type FileSystem struct {
+	// Passed to Reader.SetReadahead for each opened file. If zero the Reader default is used.
+	Readahead int64
+
+	cl *torrent.Client
+
+	mu   sync.Mutex
+	root *node
+	// Identifies the torrents, and which have their info, that root was built from.
+	rootKey string
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+func New(cl *torrent.Client) *FileSystem {
+	return &FileSystem{cl: cl}
+}
+
+func (*FileSystem) Mkdir(context.Context, string, os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (*FileSystem) RemoveAll(context.Context, string) error {
+	return os.ErrPermission
+}
+
+func (*FileSystem) Rename(context.Context, string, string) error {
+	return os.ErrPermission
+}
+
+func (me *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	n, err := me.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return n.fileInfo(), nil
+}
+
+func (me *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+	n, err := me.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if n.file == nil {
+		return &dir{node: n}, nil
+	}
+	readahead := me.Readahead
+	return &file{
+		ReadSeekCloser: n.file.NewReadSeeker(ctx, func(r torrent.Reader) {
+			if readahead != 0 {
+				r.SetReadahead(readahead)
+			}
+		}),
+		node: n,
+	}, nil
+}
+
+func (me *FileSystem) lookup(name string) (*node, error) {
+	n := me.tree()
+	for _, c := range splitPath(name) {
+		n = n.children[c]
+		if n == nil {
+			return nil, os.ErrNotExist
+		}
+	}
+	return n, nil
+}
+
+func splitPath(name string) []string {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// Returns the directory tree, rebuilding it if torrents have been added or removed, or received
+// their info, since it was last built.
+func (me *FileSystem) tree() *node {
+	ts := me.cl.Torrents()
+	sort.Slice(ts, func(i, j int) bool {
+		return ts[i].InfoHash().HexString() < ts[j].InfoHash().HexString()
+	})
+	var key strings.Builder
+	for _, t := range ts {
+		key.WriteString(t.InfoHash().HexString())
+		if t.Info() != nil {
+			key.WriteByte('+')
+		}
+		key.WriteByte(',')
+	}
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	if me.root == nil || key.String() != me.rootKey {
+		me.root = buildTree(ts)
+		me.rootKey = key.String()
+	}
+	return me.root
+}
+
+// Builds the directory tree from the torrents, which must be in infohash order so the torrent that
+// keeps the plain paths when they collide is consistent.
+func buildTree(ts []*torrent.Torrent) *node {
+	root := &node{children: make(map[string]*node)}
+	for _, t := range ts {
+		if t.Info() == nil {
+			continue
+		}
+		files := t.Files()
+		var prefix string
+		for _, f := range files {
+			if root.collides(splitPath(f.SafePath())) {
+				prefix = t.InfoHash().HexString()
+				break
+			}
+		}
+		for i, f := range files {
+			root.addFile(t, i, f, prefix)
+		}
+	}
+	return root
+}
+
+type node struct {
+	name string
+	// Set for regular files.
+	t     *torrent.Torrent
+	index int
+	file  *torrent.File
+	// Set for directories.
+	children map[string]*node
+}
+
+// Whether a file can't be added at the path, because it or one of its parent directories already
+// exists as a file, or it exists as a directory.
+func (n *node) collides(comps []string) bool {
+	if len(comps) == 0 {
+		return false
+	}
+	for _, c := range comps {
+		if n.file != nil {
+			return true
+		}
+		n = n.children[c]
+		if n == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Adds the file at its SafePath, below the prefix directory if it's not empty. The file is skipped
+// if it collides with one already added.
+func (n *node) addFile(t *torrent.Torrent, index int, f *torrent.File, prefix string) {
+	comps := splitPath(path.Join(prefix, f.SafePath()))
+	if len(comps) == 0 {
+		return
+	}
+	for _, c := range comps[:len(comps)-1] {
+		child, ok := n.children[c]
+		if !ok {
+			child = &node{name: c, children: make(map[string]*node)}
+			n.children[c] = child
+		}
+		if child.file != nil {
+			// A file already has this name.
+			return
+		}
+		n = child
+	}
+	base := comps[len(comps)-1]
+	if _, ok := n.children[base]; ok {
+		return
+	}
+	n.children[base] = &node{
+		name:  base,
+		t:     t,
+		index: index,
+		file:  f,
+	}
+}
+
+func (n *node) fileInfo() fileInfo {
+	return fileInfo{n}
+}
+
+func (n *node) sortedChildren() (ret []*node) {
+	for _, c := range n.children {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].name < ret[j].name
+	})
+	return
+}
+
+type fileInfo struct {
+	n *node
+}
+
+var (
+	_ os.FileInfo         = fileInfo{}
+	_ webdav.ETager       = fileInfo{}
+	_ webdav.ContentTyper = fileInfo{}
+)
+
+func (fi fileInfo) Name() string {
+	return fi.n.name
+}
+
+// The length is known from the info, regardless of how much data has been downloaded.
+func (fi fileInfo) Size() int64 {
+	if fi.n.file == nil {
+		return 0
+	}
+	return fi.n.file.Length()
+}
+
+func (fi fileInfo) Mode() os.FileMode {
+	if fi.IsDir() {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (fi fileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi fileInfo) IsDir() bool {
+	return fi.n.file == nil
+}
+
+func (fi fileInfo) Sys() interface{} {
+	return fi.n.file
+}
+
+// Torrent data can't change for a given infohash.
+func (fi fileInfo) ETag(context.Context) (string, error) {
+	if fi.n.file == nil {
+		return "", webdav.ErrNotImplemented
+	}
+	return fmt.Sprintf(`"%s-%d"`, fi.n.t.InfoHash().HexString(), fi.n.index), nil
+}
+
+// Avoids webdav sniffing the content type from file data, which would trigger a download.
+func (fi fileInfo) ContentType(context.Context) (string, error) {
+	if fi.n.file == nil {
+		return "", webdav.ErrNotImplemented
+	}
+	if ct := mime.TypeByExtension(path.Ext(fi.n.name)); ct != "" {
+		return ct, nil
+	}
+	return "application/octet-stream", nil
+}
+
+type dir struct {
+	node *node
+	// Children not yet returned by Readdir.
+	pending []*node
+	read    bool
+}
+
+var _ webdav.File = (*dir)(nil)
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, errors.New("is a directory")
+}
+
+func (d *dir) Write([]byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (d *dir) Seek(int64, int) (int64, error) {
+	return 0, nil
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	return d.node.fileInfo(), nil
+}
+
+// Has the semantics of os.File.Readdir.
+func (d *dir) Readdir(count int) (ret []os.FileInfo, err error) {
+	if !d.read {
+		d.pending = d.node.sortedChildren()
+		d.read = true
+	}
+	n := len(d.pending)
+	if count > 0 {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		if count < n {
+			n = count
+		}
+	}
+	for _, c := range d.pending[:n] {
+		ret = append(ret, c.fileInfo())
+	}
+	d.pending = d.pending[n:]
+	return
+}
+
+// A regular file, read with torrent.File.NewReadSeeker so opening it for its properties doesn't
+// prioritize any data.
+type file struct {
+	io.ReadSeekCloser
+	node *node
+}
+
+var _ webdav.File = (*file)(nil)
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *file) Readdir(int) ([]os.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.node.fileInfo(), nil
+}
@@ -0,0 +1,63 @@
+package torrentwebdav
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/internal/testutil"
+	"github.com/anacrolix/torrent/storage"
+)
+
+func TestFileSystemTree(t *testing.T) {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.DefaultStorage = storage.NewFile(cfg.DataDir)
+	t.Cleanup(func() { cfg.DefaultStorage.Close() })
+	cl, err := torrent.NewClient(cfg)
+	require.NoError(t, err)
+	defer cl.Close()
+	fs := New(cl)
+	ctx := context.Background()
+
+	_, err = fs.Stat(ctx, "greeting")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	a, err := cl.AddTorrent(testutil.GreetingMetaInfo())
+	require.NoError(t, err)
+	fi, err := fs.Stat(ctx, "greeting")
+	require.NoError(t, err)
+	assert.EqualValues(t, len(testutil.GreetingFileContents), fi.Size())
+	// The tree is reused until torrents change.
+	assert.Same(t, fs.tree(), fs.tree())
+
+	// Another torrent with the same file path.
+	other := testutil.Torrent{
+		Name:  testutil.GreetingFileName,
+		Files: []testutil.File{{Data: "goodbye\n"}},
+	}
+	b, err := cl.AddTorrent(other.Metainfo(5))
+	require.NoError(t, err)
+	first, second := a, b
+	if b.InfoHash().HexString() < a.InfoHash().HexString() {
+		first, second = b, a
+	}
+	fi, err = fs.Stat(ctx, "greeting")
+	require.NoError(t, err)
+	assert.Equal(t, first.Files()[0], fi.Sys())
+	fi, err = fs.Stat(ctx, second.InfoHash().HexString()+"/greeting")
+	require.NoError(t, err)
+	assert.Equal(t, second.Files()[0], fi.Sys())
+
+	first.Drop()
+	fi, err = fs.Stat(ctx, "greeting")
+	require.NoError(t, err)
+	assert.Equal(t, second.Files()[0], fi.Sys())
+}
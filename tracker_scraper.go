@@ -1,7 +1,6 @@
 package torrent
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"net"
@@ -24,33 +23,6 @@ type trackerScraper struct {
 	lastAnnounce trackerAnnounceResult
 }
 
-func (ts *trackerScraper) statusLine() string {
-	var w bytes.Buffer
-	fmt.Fprintf(&w, "%q\t%s\t%s",
-		ts.u.String(),
-		func() string {
-			na := time.Until(ts.lastAnnounce.Completed.Add(ts.lastAnnounce.Interval))
-			if na > 0 {
-				na /= time.Second
-				na *= time.Second
-				return na.String()
-			} else {
-				return "anytime"
-			}
-		}(),
-		func() string {
-			if ts.lastAnnounce.Err != nil {
-				return ts.lastAnnounce.Err.Error()
-			}
-			if ts.lastAnnounce.Completed.IsZero() {
-				return "never"
-			}
-			return fmt.Sprintf("%d peers", ts.lastAnnounce.NumPeers)
-		}(),
-	)
-	return w.String()
-}
-
 type trackerAnnounceResult struct {
 	Err       error
 	NumPeers  int
@@ -138,6 +110,12 @@ func (me *trackerScraper) Run() {
 		ar := me.announce()
 		me.t.cl.lock()
 		me.lastAnnounce = ar
+		me.t.publishEvent(Event{
+			Type:     EventTrackerAnnounce,
+			Tracker:  me.u.String(),
+			NumPeers: ar.NumPeers,
+			Err:      ar.Err,
+		})
 		me.t.cl.unlock()
 
 	wait:
@@ -0,0 +1,284 @@
+package transmissionrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/anacrolix/torrent"
+)
+
+type torrentGetArgs struct {
+	idsArgs
+	Fields []string `json:"fields"`
+	Format string   `json:"format"`
+}
+
+func (h *Handler) torrentGet(args json.RawMessage) (interface{}, error) {
+	var a torrentGetArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Format != "" && a.Format != "objects" {
+		return nil, fmt.Errorf("unsupported format %q", a.Format)
+	}
+	// Like Transmission, fields that aren't known are left out.
+	var fields []string
+	for _, f := range a.Fields {
+		if _, ok := torrentFields[f]; ok {
+			fields = append(fields, f)
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	es, err := h.resolveIds(a.Ids)
+	if err != nil {
+		return nil, err
+	}
+	torrents := make([]map[string]interface{}, 0, len(es))
+	for _, e := range es {
+		s := e.snapshot()
+		s.downloadDir = h.DownloadDir
+		obj := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			obj[f] = torrentFields[f](s)
+		}
+		torrents = append(torrents, obj)
+	}
+	return map[string]interface{}{"torrents": torrents}, nil
+}
+
+type fileSnapshot struct {
+	name           string
+	length         int64
+	bytesCompleted int64
+	wanted         bool
+	priority       int
+}
+
+// The state of a torrent needed to produce any of the torrentFields.
+type torrentSnapshot struct {
+	e         *entry
+	name      string
+	hasInfo   bool
+	totalSize int64
+	checking  bool
+	complete  bool
+	files     []fileSnapshot
+	stats     torrent.TorrentStats
+	down, up  float64
+	magnet    string
+	// Handler.DownloadDir.
+	downloadDir string
+}
+
+func (s *torrentSnapshot) sizeWhenDone() (ret int64) {
+	for _, f := range s.files {
+		if f.wanted {
+			ret += f.length
+		}
+	}
+	return
+}
+
+func (s *torrentSnapshot) leftUntilDone() (ret int64) {
+	for _, f := range s.files {
+		if f.wanted {
+			ret += f.length - f.bytesCompleted
+		}
+	}
+	return
+}
+
+func (s *torrentSnapshot) percentDone() float64 {
+	size := s.sizeWhenDone()
+	if size == 0 {
+		if s.hasInfo {
+			return 1
+		}
+		return 0
+	}
+	return float64(size-s.leftUntilDone()) / float64(size)
+}
+
+func (s *torrentSnapshot) status() int {
+	switch {
+	case s.e.stopped:
+		return statusStopped
+	case s.checking:
+		return statusCheck
+	case s.hasInfo && s.leftUntilDone() == 0:
+		return statusSeed
+	default:
+		return statusDownload
+	}
+}
+
+// Transmission uses -1 for "not available" and -2 for "unknown".
+func (s *torrentSnapshot) eta() int64 {
+	left := s.leftUntilDone()
+	switch {
+	case s.hasInfo && left == 0:
+		return 0
+	case s.e.stopped || s.down < 1:
+		return -1
+	}
+	return int64(math.Ceil(float64(left) / s.down))
+}
+
+// Must be called with Handler.mu held.
+func (e *entry) snapshot() *torrentSnapshot {
+	s := &torrentSnapshot{
+		e:    e,
+		name: e.displayName(),
+	}
+	t := e.t
+	if e.stopped {
+		if e.mi != nil {
+			info, _ := e.mi.UnmarshalInfo()
+			s.hasInfo = true
+			s.totalSize = info.TotalLength()
+			// File indices match Torrent.Files, which excludes padding.
+			for _, fi := range info.UpvertedFiles() {
+				if fi.IsPad() {
+					continue
+				}
+				s.files = append(s.files, e.fileSnapshot(len(s.files), fi.DisplayPath(&info), fi.Length, 0))
+			}
+			m := e.mi.Magnet(&e.ih, &info)
+			s.magnet = m.String()
+		} else {
+			s.magnet = e.magnet
+		}
+		return s
+	}
+	s.stats = t.Stats()
+	s.down, s.up = e.rates(s.stats)
+	mi := t.Metainfo()
+	info := t.Info()
+	m := mi.Magnet(&e.ih, info)
+	if info == nil {
+		m.DisplayName = s.name
+	}
+	s.magnet = m.String()
+	if info == nil {
+		return s
+	}
+	s.hasInfo = true
+	s.totalSize = t.Length()
+	for i, f := range t.Files() {
+		var completed int64
+		for _, ps := range f.State() {
+			if ps.Complete {
+				completed += ps.Bytes
+			}
+			if ps.Checking {
+				s.checking = true
+			}
+		}
+		fs := e.fileSnapshot(i, f.DisplayPath(), f.Length(), completed)
+		if !e.fileSelected(i) {
+			// Report the priority set outside the Handler.
+			switch prio := f.Priority(); {
+			case prio == torrent.PiecePriorityNone:
+				fs.wanted = false
+			case prio > torrent.PiecePriorityNormal:
+				fs.priority = priorityHigh
+			}
+		}
+		s.files = append(s.files, fs)
+	}
+	return s
+}
+
+func (e *entry) fileSnapshot(i int, name string, length, completed int64) fileSnapshot {
+	return fileSnapshot{
+		name:           name,
+		length:         length,
+		bytesCompleted: completed,
+		wanted:         e.fileWanted(i),
+		priority:       e.priorities[i],
+	}
+}
+
+type fileObject struct {
+	BytesCompleted int64  `json:"bytesCompleted"`
+	Length         int64  `json:"length"`
+	Name           string `json:"name"`
+}
+
+type fileStatsObject struct {
+	BytesCompleted int64 `json:"bytesCompleted"`
+	Wanted         bool  `json:"wanted"`
+	Priority       int   `json:"priority"`
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// The supported torrent-get fields.
+var torrentFields = map[string]func(*torrentSnapshot) interface{}{
+	"id":           func(s *torrentSnapshot) interface{} { return s.e.id },
+	"name":         func(s *torrentSnapshot) interface{} { return s.name },
+	"hashString":   func(s *torrentSnapshot) interface{} { return s.e.ih.HexString() },
+	"addedDate":    func(s *torrentSnapshot) interface{} { return s.e.added.Unix() },
+	"totalSize":    func(s *torrentSnapshot) interface{} { return s.totalSize },
+	"sizeWhenDone": func(s *torrentSnapshot) interface{} { return s.sizeWhenDone() },
+	"leftUntilDone": func(s *torrentSnapshot) interface{} {
+		return s.leftUntilDone()
+	},
+	"percentDone": func(s *torrentSnapshot) interface{} { return s.percentDone() },
+	"status":      func(s *torrentSnapshot) interface{} { return s.status() },
+	"isFinished": func(s *torrentSnapshot) interface{} {
+		return s.hasInfo && s.leftUntilDone() == 0
+	},
+	"eta":          func(s *torrentSnapshot) interface{} { return s.eta() },
+	"rateDownload": func(s *torrentSnapshot) interface{} { return int64(s.down) },
+	"rateUpload":   func(s *torrentSnapshot) interface{} { return int64(s.up) },
+	"downloadedEver": func(s *torrentSnapshot) interface{} {
+		return s.stats.BytesReadUsefulData.Int64()
+	},
+	"uploadedEver": func(s *torrentSnapshot) interface{} {
+		return s.stats.BytesWrittenData.Int64()
+	},
+	"peersConnected": func(s *torrentSnapshot) interface{} { return s.stats.ActivePeers },
+	"metadataPercentComplete": func(s *torrentSnapshot) interface{} {
+		return boolInt(s.hasInfo)
+	},
+	"magnetLink":  func(s *torrentSnapshot) interface{} { return s.magnet },
+	"downloadDir": func(s *torrentSnapshot) interface{} { return s.downloadDir },
+	"error":       func(s *torrentSnapshot) interface{} { return 0 },
+	"errorString": func(s *torrentSnapshot) interface{} { return "" },
+	"files": func(s *torrentSnapshot) interface{} {
+		ret := make([]fileObject, 0, len(s.files))
+		for _, f := range s.files {
+			ret = append(ret, fileObject{f.bytesCompleted, f.length, f.name})
+		}
+		return ret
+	},
+	"fileStats": func(s *torrentSnapshot) interface{} {
+		ret := make([]fileStatsObject, 0, len(s.files))
+		for _, f := range s.files {
+			ret = append(ret, fileStatsObject{f.bytesCompleted, f.wanted, f.priority})
+		}
+		return ret
+	},
+	"wanted": func(s *torrentSnapshot) interface{} {
+		ret := make([]int, 0, len(s.files))
+		for _, f := range s.files {
+			ret = append(ret, boolInt(f.wanted))
+		}
+		return ret
+	},
+	"priorities": func(s *torrentSnapshot) interface{} {
+		ret := make([]int, 0, len(s.files))
+		for _, f := range s.files {
+			ret = append(ret, f.priority)
+		}
+		return ret
+	},
+}
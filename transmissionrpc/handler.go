@@ -0,0 +1,178 @@
+// Package transmissionrpc implements the core of the Transmission RPC protocol over a Client, so
+// that existing Transmission front-ends can drive it. See
+// https://github.com/transmission/transmission/blob/main/docs/rpc-spec.md.
+package transmissionrpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const sessionIdHeader = "X-Transmission-Session-Id"
+
+// Serves Transmission RPC requests, usually at "/transmission/rpc". Torrents are identified by
+// small integer IDs assigned by the Handler, or by their infohash. Stopped torrents are dropped from
+// the Client and added again when started, with their file selections restored.
+type Handler struct {
+	cl *torrent.Client
+	// Reported to clients as the download directory. Torrents are always stored per the Client's
+	// configuration.
+	DownloadDir string
+
+	sessionId string
+	started   time.Time
+
+	mu      sync.Mutex
+	nextId  int
+	entries map[metainfo.Hash]*entry
+	byId    map[int]*entry
+}
+
+func NewHandler(cl *torrent.Client) *Handler {
+	var b [16]byte
+	rand.Read(b[:])
+	return &Handler{
+		cl:        cl,
+		sessionId: hex.EncodeToString(b[:]),
+		started:   time.Now(),
+		nextId:    1,
+		entries:   make(map[metainfo.Hash]*entry),
+		byId:      make(map[int]*entry),
+	}
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+type request struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments"`
+	Tag       *int64          `json:"tag,omitempty"`
+}
+
+type response struct {
+	Result    string      `json:"result"`
+	Arguments interface{} `json:"arguments"`
+	Tag       *int64      `json:"tag,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Transmission's CSRF protection. Clients retry with the ID from the 409 response.
+	if r.Header.Get(sessionIdHeader) != h.sessionId {
+		w.Header().Set(sessionIdHeader, h.sessionId)
+		http.Error(w, fmt.Sprintf("%s: %s", sessionIdHeader, h.sessionId), http.StatusConflict)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Arguments) == 0 {
+		req.Arguments = json.RawMessage("{}")
+	}
+	args, err := h.call(req.Method, req.Arguments)
+	resp := response{
+		Result:    "success",
+		Arguments: args,
+		Tag:       req.Tag,
+	}
+	if err != nil {
+		resp.Result = err.Error()
+	}
+	if resp.Arguments == nil {
+		resp.Arguments = struct{}{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) call(method string, args json.RawMessage) (interface{}, error) {
+	switch method {
+	case "torrent-add":
+		return h.torrentAdd(args)
+	case "torrent-get":
+		return h.torrentGet(args)
+	case "torrent-set":
+		return nil, h.torrentSet(args)
+	case "torrent-start", "torrent-start-now":
+		return nil, h.forIdsArgs(args, h.start)
+	case "torrent-stop":
+		return nil, h.forIdsArgs(args, h.stop)
+	case "torrent-remove":
+		return nil, h.torrentRemove(args)
+	case "session-get":
+		return h.sessionGet(), nil
+	case "session-stats":
+		return h.sessionStats(), nil
+	}
+	return nil, fmt.Errorf("method name not recognized")
+}
+
+func (h *Handler) sessionGet() map[string]interface{} {
+	return map[string]interface{}{
+		"version":             "2.94 (anacrolix/torrent)",
+		"rpc-version":         15,
+		"rpc-version-minimum": 1,
+		"session-id":          h.sessionId,
+		"download-dir":        h.DownloadDir,
+		"peer-port":           h.cl.LocalPort(),
+	}
+}
+
+type sessionStatsTotals struct {
+	UploadedBytes   int64 `json:"uploadedBytes"`
+	DownloadedBytes int64 `json:"downloadedBytes"`
+	FilesAdded      int   `json:"filesAdded"`
+	SessionCount    int   `json:"sessionCount"`
+	SecondsActive   int64 `json:"secondsActive"`
+}
+
+func (h *Handler) sessionStats() map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.syncEntries()
+	var active, paused int
+	var down, up float64
+	totals := sessionStatsTotals{
+		SessionCount:  1,
+		SecondsActive: int64(time.Since(h.started).Seconds()),
+	}
+	for _, e := range h.entries {
+		if e.stopped {
+			paused++
+			continue
+		}
+		active++
+		t := e.t
+		stats := t.Stats()
+		totals.UploadedBytes += stats.BytesWrittenData.Int64()
+		totals.DownloadedBytes += stats.BytesReadUsefulData.Int64()
+		if t.Info() != nil {
+			totals.FilesAdded += len(t.Files())
+		}
+		d, u := e.rates(stats)
+		down += d
+		up += u
+	}
+	return map[string]interface{}{
+		"activeTorrentCount": active,
+		"pausedTorrentCount": paused,
+		"torrentCount":       len(h.entries),
+		"downloadSpeed":      int64(down),
+		"uploadSpeed":        int64(up),
+		"current-stats":      totals,
+		"cumulative-stats":   totals,
+	}
+}
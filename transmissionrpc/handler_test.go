@@ -0,0 +1,228 @@
+package transmissionrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/internal/testutil"
+	"github.com/anacrolix/torrent/storage"
+)
+
+type testServer struct {
+	t         *testing.T
+	h         *Handler
+	srv       *httptest.Server
+	sessionId string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.DefaultStorage = storage.NewFile(cfg.DataDir)
+	t.Cleanup(func() { cfg.DefaultStorage.Close() })
+	cl, err := torrent.NewClient(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { cl.Close() })
+	h := NewHandler(cl)
+	h.DownloadDir = "/downloads"
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return &testServer{t: t, h: h, srv: srv}
+}
+
+func (ts *testServer) post(body []byte) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, ts.srv.URL, bytes.NewReader(body))
+	require.NoError(ts.t, err)
+	req.Header.Set(sessionIdHeader, ts.sessionId)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(ts.t, err)
+	return resp
+}
+
+// Calls method, getting a session ID first if necessary, and decodes the arguments into ret.
+func (ts *testServer) call(method string, args interface{}, ret interface{}) (result string) {
+	body, err := json.Marshal(map[string]interface{}{"method": method, "arguments": args})
+	require.NoError(ts.t, err)
+	resp := ts.post(body)
+	if resp.StatusCode == http.StatusConflict {
+		resp.Body.Close()
+		ts.sessionId = resp.Header.Get(sessionIdHeader)
+		resp = ts.post(body)
+	}
+	defer resp.Body.Close()
+	require.Equal(ts.t, http.StatusOK, resp.StatusCode)
+	var r struct {
+		Result    string          `json:"result"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	require.NoError(ts.t, json.NewDecoder(resp.Body).Decode(&r))
+	if ret != nil {
+		require.NoError(ts.t, json.Unmarshal(r.Arguments, ret))
+	}
+	return r.Result
+}
+
+type testTorrent struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	HashString  string `json:"hashString"`
+	Status      int    `json:"status"`
+	DownloadDir string `json:"downloadDir"`
+}
+
+func (ts *testServer) get(ids interface{}) []testTorrent {
+	var ret struct {
+		Torrents []testTorrent `json:"torrents"`
+	}
+	result := ts.call("torrent-get", map[string]interface{}{
+		"ids":    ids,
+		"fields": []string{"id", "name", "hashString", "status", "downloadDir", "notAField"},
+	}, &ret)
+	require.Equal(ts.t, "success", result)
+	return ret.Torrents
+}
+
+func TestSessionIdHandshake(t *testing.T) {
+	ts := newTestServer(t)
+	resp := ts.post([]byte(`{"method":"session-get"}`))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	ts.sessionId = resp.Header.Get(sessionIdHeader)
+	assert.NotEmpty(t, ts.sessionId)
+	var session struct {
+		SessionId   string `json:"session-id"`
+		DownloadDir string `json:"download-dir"`
+	}
+	assert.Equal(t, "success", ts.call("session-get", nil, &session))
+	assert.Equal(t, ts.sessionId, session.SessionId)
+	assert.Equal(t, "/downloads", session.DownloadDir)
+}
+
+func TestTorrentAddGetStopStart(t *testing.T) {
+	ts := newTestServer(t)
+	mi := testutil.GreetingMetaInfo()
+	var buf bytes.Buffer
+	require.NoError(t, mi.Write(&buf))
+	metainfo := base64.StdEncoding.EncodeToString(buf.Bytes())
+	var added struct {
+		Added     *testTorrent `json:"torrent-added"`
+		Duplicate *testTorrent `json:"torrent-duplicate"`
+	}
+	require.Equal(t, "success", ts.call("torrent-add", map[string]interface{}{"metainfo": metainfo}, &added))
+	require.NotNil(t, added.Added)
+	assert.Equal(t, 1, added.Added.Id)
+	hash := mi.HashInfoBytes().HexString()
+	assert.Equal(t, hash, added.Added.HashString)
+	added.Added = nil
+	require.Equal(t, "success", ts.call("torrent-add", map[string]interface{}{"metainfo": metainfo}, &added))
+	assert.Nil(t, added.Added)
+	require.NotNil(t, added.Duplicate)
+	assert.Equal(t, 1, added.Duplicate.Id)
+
+	got := ts.get(nil)
+	require.Len(t, got, 1)
+	assert.Equal(t, testTorrent{
+		Id:          1,
+		Name:        testutil.GreetingFileName,
+		HashString:  hash,
+		Status:      statusDownload,
+		DownloadDir: "/downloads",
+	}, got[0])
+
+	require.Equal(t, "success", ts.call("torrent-stop", map[string]interface{}{"ids": 1}, nil))
+	assert.Empty(t, ts.h.cl.Torrents())
+	got = ts.get(nil)
+	require.Len(t, got, 1)
+	assert.Equal(t, statusStopped, got[0].Status)
+	assert.Equal(t, testutil.GreetingFileName, got[0].Name)
+
+	require.Equal(t, "success", ts.call("torrent-start", map[string]interface{}{"ids": []interface{}{hash}}, nil))
+	assert.Len(t, ts.h.cl.Torrents(), 1)
+	got = ts.get(nil)
+	require.Len(t, got, 1)
+	assert.NotEqual(t, statusStopped, got[0].Status)
+}
+
+func TestResolveIds(t *testing.T) {
+	ts := newTestServer(t)
+	a, err := ts.h.cl.AddTorrent(testutil.GreetingMetaInfo())
+	require.NoError(t, err)
+	other := testutil.Torrent{Name: "other", Files: []testutil.File{{Data: "other"}}}
+	b, err := ts.h.cl.AddTorrent(other.Metainfo(5))
+	require.NoError(t, err)
+	// Torrents added to the Client directly are picked up, and get IDs in the order seen.
+	all := ts.get("recently-active")
+	require.Len(t, all, 2)
+	ids := map[string]int{}
+	for _, tt := range all {
+		ids[tt.HashString] = tt.Id
+	}
+	aId, bId := ids[a.InfoHash().HexString()], ids[b.InfoHash().HexString()]
+	assert.ElementsMatch(t, []int{1, 2}, []int{aId, bId})
+
+	got := ts.get(aId)
+	require.Len(t, got, 1)
+	assert.Equal(t, aId, got[0].Id)
+	got = ts.get(b.InfoHash().HexString())
+	require.Len(t, got, 1)
+	assert.Equal(t, bId, got[0].Id)
+	got = ts.get([]interface{}{bId, a.InfoHash().HexString(), 99})
+	require.Len(t, got, 2)
+	assert.Equal(t, []int{bId, aId}, []int{got[0].Id, got[1].Id})
+	assert.Empty(t, ts.get(99))
+	assert.NotEqual(t, "success", ts.call("torrent-get", map[string]interface{}{
+		"ids":    "zz",
+		"fields": []string{"id"},
+	}, nil))
+}
+
+func TestExternalFilePrioritiesKept(t *testing.T) {
+	ts := newTestServer(t)
+	tor, err := ts.h.cl.AddTorrent(testutil.GreetingMetaInfo())
+	require.NoError(t, err)
+	<-tor.GotInfo()
+	f := tor.Files()[0]
+	f.SetPriority(torrent.PiecePriorityHigh)
+	getFiles := func() (ret struct {
+		Wanted     []int `json:"wanted"`
+		Priorities []int `json:"priorities"`
+	},
+	) {
+		var got struct {
+			Torrents []json.RawMessage `json:"torrents"`
+		}
+		require.Equal(t, "success", ts.call("torrent-get", map[string]interface{}{
+			"fields": []string{"wanted", "priorities"},
+		}, &got))
+		require.Len(t, got.Torrents, 1)
+		require.NoError(t, json.Unmarshal(got.Torrents[0], &ret))
+		return
+	}
+	files := getFiles()
+	assert.Equal(t, []int{1}, files.Wanted)
+	assert.Equal(t, []int{priorityHigh}, files.Priorities)
+	require.Equal(t, "success", ts.call("session-stats", nil, nil))
+	assert.Never(t, func() bool {
+		return f.Priority() != torrent.PiecePriorityHigh
+	}, 100*time.Millisecond, time.Millisecond)
+
+	require.Equal(t, "success", ts.call("torrent-set", map[string]interface{}{
+		"files-unwanted": []int{0},
+	}, nil))
+	assert.Equal(t, torrent.PiecePriorityNone, f.Priority())
+	files = getFiles()
+	assert.Equal(t, []int{0}, files.Wanted)
+}
@@ -0,0 +1,474 @@
+package transmissionrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Transmission torrent status values.
+const (
+	statusStopped     = 0
+	statusCheck       = 2
+	statusDownload    = 4
+	statusSeed        = 6
+	priorityLow       = -1
+	priorityNormal    = 0
+	priorityHigh      = 1
+	maxMetainfoLength = 64 << 20
+)
+
+// Handler state for a torrent. Stopped torrents have no Torrent, and keep what's needed to add them
+// again.
+type entry struct {
+	id      int
+	ih      metainfo.Hash
+	t       *torrent.Torrent
+	stopped bool
+	added   time.Time
+	mi      *metainfo.MetaInfo
+	magnet  string
+	name    string
+	// File selections by index, made through torrent-add or torrent-set. Selected files not present
+	// are wanted at normal priority.
+	unwanted   map[int]bool
+	priorities map[int]int
+	// Files that have had a selection made. Others keep whatever priority they have in the Client,
+	// such as one set through the library or from a BEP 53 magnet link. All files of torrents added
+	// through torrent-add are selected.
+	selected    map[int]bool
+	allSelected bool
+
+	rateSampled      time.Time
+	lastRead         int64
+	lastWritten      int64
+	downRate, upRate float64
+}
+
+// Returns the download and upload rates in bytes per second, resampling at most once a second.
+func (e *entry) rates(stats torrent.TorrentStats) (down, up float64) {
+	now := time.Now()
+	read := stats.BytesReadUsefulData.Int64()
+	written := stats.BytesWrittenData.Int64()
+	if e.rateSampled.IsZero() {
+		e.rateSampled, e.lastRead, e.lastWritten = now, read, written
+	} else if elapsed := now.Sub(e.rateSampled).Seconds(); elapsed >= 1 {
+		e.downRate = float64(read-e.lastRead) / elapsed
+		e.upRate = float64(written-e.lastWritten) / elapsed
+		e.rateSampled, e.lastRead, e.lastWritten = now, read, written
+	}
+	return e.downRate, e.upRate
+}
+
+func (e *entry) fileSelected(i int) bool {
+	return e.allSelected || e.selected[i]
+}
+
+func (e *entry) fileWanted(i int) bool {
+	return !e.unwanted[i]
+}
+
+func (e *entry) setFilePriority(f *torrent.File, i int) {
+	switch {
+	case !e.fileWanted(i):
+		f.SetPriority(torrent.PiecePriorityNone)
+	case e.priorities[i] == priorityHigh:
+		f.SetPriority(torrent.PiecePriorityHigh)
+	default:
+		f.SetPriority(torrent.PiecePriorityNormal)
+	}
+}
+
+// Sets the priorities of the files for which apply returns true on the Torrent, if it's running and
+// has its info. Must be called with Handler.mu held.
+func (e *entry) applyFilePriorities(apply func(int) bool) {
+	if e.t == nil || e.t.Info() == nil {
+		return
+	}
+	for i, f := range e.t.Files() {
+		if apply(i) {
+			e.setFilePriority(f, i)
+		}
+	}
+}
+
+func (h *Handler) newEntry(t *torrent.Torrent) *entry {
+	e := &entry{
+		id:         h.nextId,
+		ih:         t.InfoHash(),
+		t:          t,
+		added:      time.Now(),
+		unwanted:   make(map[int]bool),
+		priorities: make(map[int]int),
+		selected:   make(map[int]bool),
+	}
+	h.nextId++
+	h.entries[e.ih] = e
+	h.byId[e.id] = e
+	go h.onInfo(e, t)
+	return e
+}
+
+// Applies the file selections once the info is available.
+func (h *Handler) onInfo(e *entry, t *torrent.Torrent) {
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e.t == t {
+		e.applyFilePriorities(e.fileSelected)
+	}
+}
+
+// Picks up torrents added to or dropped from the Client other than through the Handler. Must be
+// called with mu held.
+func (h *Handler) syncEntries() {
+	for _, t := range h.cl.Torrents() {
+		if e, ok := h.entries[t.InfoHash()]; ok {
+			if e.stopped {
+				e.t = t
+				e.stopped = false
+				go h.onInfo(e, t)
+			}
+			continue
+		}
+		h.newEntry(t)
+	}
+	for ih, e := range h.entries {
+		if e.stopped {
+			continue
+		}
+		select {
+		case <-e.t.Closed():
+			delete(h.entries, ih)
+			delete(h.byId, e.id)
+		default:
+		}
+	}
+}
+
+func (h *Handler) sortedEntries() (ret []*entry) {
+	for _, e := range h.entries {
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].id < ret[j].id })
+	return
+}
+
+// Resolves the "ids" argument, which may be absent or null (all torrents), a single ID, a hash
+// string, or a list of either. "recently-active" is treated as all torrents. Must be called with mu
+// held.
+func (h *Handler) resolveIds(raw json.RawMessage) (ret []*entry, err error) {
+	h.syncEntries()
+	if len(raw) == 0 || string(raw) == "null" || string(raw) == `"recently-active"` {
+		return h.sortedEntries(), nil
+	}
+	var list []json.RawMessage
+	if raw[0] == '[' {
+		if err = json.Unmarshal(raw, &list); err != nil {
+			return
+		}
+	} else {
+		list = []json.RawMessage{raw}
+	}
+	for _, item := range list {
+		var e *entry
+		e, err = h.resolveId(item)
+		if err != nil {
+			return
+		}
+		if e != nil {
+			ret = append(ret, e)
+		}
+	}
+	return
+}
+
+// Returns nil if the torrent isn't known, as Transmission ignores those.
+func (h *Handler) resolveId(raw json.RawMessage) (*entry, error) {
+	var id int
+	if json.Unmarshal(raw, &id) == nil {
+		return h.byId[id], nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid id %s", raw)
+	}
+	var ih metainfo.Hash
+	if err := ih.FromHexString(s); err != nil {
+		return nil, fmt.Errorf("invalid hash string %q: %w", s, err)
+	}
+	return h.entries[ih], nil
+}
+
+type idsArgs struct {
+	Ids json.RawMessage `json:"ids"`
+}
+
+// Calls f for each torrent selected by the arguments. See resolveIds.
+func (h *Handler) forIdsArgs(args json.RawMessage, f func(*entry) error) error {
+	var a idsArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return err
+	}
+	return h.forIds(a.Ids, f)
+}
+
+func (h *Handler) forIds(ids json.RawMessage, f func(*entry) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	es, err := h.resolveIds(ids)
+	if err != nil {
+		return err
+	}
+	for _, e := range es {
+		if err := f(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drops the torrent from the Client, keeping enough to add it again. Must be called with mu held.
+func (h *Handler) stop(e *entry) error {
+	if e.stopped {
+		return nil
+	}
+	t := e.t
+	e.name = t.Name()
+	if t.Info() != nil {
+		mi := t.Metainfo()
+		e.mi = &mi
+	} else {
+		mi := t.Metainfo()
+		m := mi.Magnet(&e.ih, nil)
+		m.DisplayName = e.name
+		e.magnet = m.String()
+	}
+	t.Drop()
+	e.t = nil
+	e.stopped = true
+	e.rateSampled = time.Time{}
+	e.downRate, e.upRate = 0, 0
+	return nil
+}
+
+// Adds a stopped torrent back to the Client. Must be called with mu held.
+func (h *Handler) start(e *entry) (err error) {
+	if !e.stopped {
+		return nil
+	}
+	var t *torrent.Torrent
+	if e.mi != nil {
+		t, err = h.cl.AddTorrent(e.mi)
+	} else {
+		t, err = h.cl.AddMagnet(e.magnet)
+	}
+	if err != nil {
+		return
+	}
+	e.t = t
+	e.stopped = false
+	go h.onInfo(e, t)
+	return
+}
+
+type torrentRemoveArgs struct {
+	idsArgs
+	DeleteLocalData bool `json:"delete-local-data"`
+}
+
+func (h *Handler) torrentRemove(args json.RawMessage) error {
+	var a torrentRemoveArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return err
+	}
+	if a.DeleteLocalData {
+		return errors.New("delete-local-data is not supported")
+	}
+	return h.forIds(a.Ids, func(e *entry) error {
+		if !e.stopped {
+			e.t.Drop()
+		}
+		delete(h.entries, e.ih)
+		delete(h.byId, e.id)
+		return nil
+	})
+}
+
+type torrentAddArgs struct {
+	Filename       string `json:"filename"`
+	Metainfo       string `json:"metainfo"`
+	Paused         bool   `json:"paused"`
+	FilesWanted    []int  `json:"files-wanted"`
+	FilesUnwanted  []int  `json:"files-unwanted"`
+	PriorityHigh   []int  `json:"priority-high"`
+	PriorityLow    []int  `json:"priority-low"`
+	PriorityNormal []int  `json:"priority-normal"`
+}
+
+type addedTorrent struct {
+	Id         int    `json:"id"`
+	Name       string `json:"name"`
+	HashString string `json:"hashString"`
+}
+
+func (h *Handler) torrentAdd(args json.RawMessage) (interface{}, error) {
+	var a torrentAddArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	var (
+		mi     *metainfo.MetaInfo
+		magnet string
+		err    error
+	)
+	switch {
+	case a.Metainfo != "":
+		var b []byte
+		b, err = base64.StdEncoding.DecodeString(a.Metainfo)
+		if err == nil {
+			mi, err = metainfo.Load(bytes.NewReader(b))
+		}
+	case strings.HasPrefix(a.Filename, "magnet:"):
+		magnet = a.Filename
+	case strings.HasPrefix(a.Filename, "http://"), strings.HasPrefix(a.Filename, "https://"):
+		mi, err = fetchMetainfo(a.Filename)
+	case a.Filename != "":
+		mi, err = metainfo.LoadFromFile(a.Filename)
+	default:
+		err = errors.New("no filename or metainfo specified")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ih metainfo.Hash
+	if mi != nil {
+		if _, err := mi.UnmarshalInfo(); err != nil {
+			return nil, fmt.Errorf("invalid metainfo: %w", err)
+		}
+		ih = mi.HashInfoBytes()
+	} else {
+		m, err := metainfo.ParseMagnetUri(magnet)
+		if err != nil {
+			return nil, err
+		}
+		ih = m.InfoHash
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.syncEntries()
+	if e, ok := h.entries[ih]; ok {
+		return map[string]interface{}{"torrent-duplicate": e.addResult()}, nil
+	}
+	var t *torrent.Torrent
+	if mi != nil {
+		t, err = h.cl.AddTorrent(mi)
+	} else {
+		t, err = h.cl.AddMagnet(magnet)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := h.newEntry(t)
+	e.allSelected = true
+	e.setFiles(a.FilesWanted, a.FilesUnwanted, a.PriorityHigh, a.PriorityLow, a.PriorityNormal)
+	e.applyFilePriorities(e.fileSelected)
+	if a.Paused {
+		h.stop(e)
+	}
+	return map[string]interface{}{"torrent-added": e.addResult()}, nil
+}
+
+func (e *entry) addResult() addedTorrent {
+	return addedTorrent{
+		Id:         e.id,
+		Name:       e.displayName(),
+		HashString: e.ih.HexString(),
+	}
+}
+
+func (e *entry) displayName() string {
+	if e.stopped {
+		return e.name
+	}
+	return e.t.Name()
+}
+
+// Records the selections, returning the files they were made for.
+func (e *entry) setFiles(wanted, unwanted, high, low, normal []int) (changed map[int]bool) {
+	changed = make(map[int]bool)
+	for _, i := range wanted {
+		delete(e.unwanted, i)
+		changed[i] = true
+	}
+	for _, i := range unwanted {
+		e.unwanted[i] = true
+		changed[i] = true
+	}
+	for _, i := range high {
+		e.priorities[i] = priorityHigh
+		changed[i] = true
+	}
+	for _, i := range low {
+		e.priorities[i] = priorityLow
+		changed[i] = true
+	}
+	for _, i := range normal {
+		delete(e.priorities, i)
+		changed[i] = true
+	}
+	for i := range changed {
+		e.selected[i] = true
+	}
+	return
+}
+
+// For fetching metainfo given by URL to torrent-add.
+var httpClient = &http.Client{Timeout: time.Minute}
+
+func fetchMetainfo(url string) (*metainfo.MetaInfo, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %q: %s", url, resp.Status)
+	}
+	return metainfo.Load(io.LimitReader(resp.Body, maxMetainfoLength))
+}
+
+type torrentSetArgs struct {
+	idsArgs
+	FilesWanted    []int `json:"files-wanted"`
+	FilesUnwanted  []int `json:"files-unwanted"`
+	PriorityHigh   []int `json:"priority-high"`
+	PriorityLow    []int `json:"priority-low"`
+	PriorityNormal []int `json:"priority-normal"`
+}
+
+func (h *Handler) torrentSet(args json.RawMessage) error {
+	var a torrentSetArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return err
+	}
+	return h.forIds(a.Ids, func(e *entry) error {
+		changed := e.setFiles(a.FilesWanted, a.FilesUnwanted, a.PriorityHigh, a.PriorityLow, a.PriorityNormal)
+		e.applyFilePriorities(func(i int) bool { return changed[i] })
+		return nil
+	})
+}